@@ -6,10 +6,13 @@ package main
 
 import (
 	"context"
+	"flag"
 	"image/color"
 	"log"
+	"os"
 
 	"github.com/octo/das/dkb4q"
+	"github.com/octo/das/driver"
 )
 
 var colors = []color.NRGBA{
@@ -19,21 +22,50 @@ var colors = []color.NRGBA{
 	{R: 15, G: 157, B: 88},
 }
 
+var verbose = flag.Bool("verbose", false, "print all communication with the keyboard")
+
 func main() {
+	flag.Parse()
 	ctx := context.Background()
 
-	kb, err := dkb4q.Open()
+	var opts []driver.Option
+	if *verbose {
+		opts = append(opts, driver.Tracing(os.Stdout))
+	}
+
+	kb, err := driver.OpenAny(ctx, opts...)
 	if err != nil {
 		log.Fatal(err)
 	}
 	defer kb.Close()
 
+	// The 4Q supports an active color, shown while a key is pressed.
+	if kb4q, ok := kb.(*dkb4q.Keyboard); ok {
+		if err := setAll4Q(ctx, kb4q); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	for i, key := range kb.Keys() {
+		if err := kb.SetColor(ctx, colors[i%len(colors)], key); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if err := kb.Commit(ctx); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// setAll4Q sets all LEDs of a 4Q, using the inverted color as active color.
+func setAll4Q(ctx context.Context, kb *dkb4q.Keyboard) error {
 	var states []dkb4q.State
 	for i := 0; i <= dkb4q.MaxID; i++ {
 		c := colors[i%len(colors)]
 
 		states = append(states, dkb4q.State{
-			ID:           uint8(i),
+			ID:           dkb4q.Key(i),
 			IdleEffect:   dkb4q.SetColor,
 			IdleColor:    c,
 			ActiveEffect: dkb4q.SetColorActive(),
@@ -41,7 +73,5 @@ func main() {
 		})
 	}
 
-	if err := kb.SetState(ctx, states...); err != nil {
-		log.Fatal(err)
-	}
+	return kb.SetState(ctx, states...)
 }
@@ -5,31 +5,38 @@ import (
 	"bufio"
 	"bytes"
 	"context"
+	"flag"
 	"image/color"
 	"io/ioutil"
 	"log"
 	"math"
+	"os"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/octo/das/dkb4q"
+	"github.com/octo/das/driver"
+	"github.com/octo/das/internal/cli"
 )
 
-var keys = []uint8{
-	0x11, 0x17, 0x1D, 0x23, // F1–F4
-	0x29, 0x2F, 0x35, 0x3b, // F5–F8
-	0x41, 0x47, 0x4D, 0x53, // F9–F12
+var keys = []dkb4q.Key{
+	dkb4q.KeyF1, dkb4q.KeyF2, dkb4q.KeyF3, dkb4q.KeyF4,
+	dkb4q.KeyF5, dkb4q.KeyF6, dkb4q.KeyF7, dkb4q.KeyF8,
+	dkb4q.KeyF9, dkb4q.KeyF10, dkb4q.KeyF11, dkb4q.KeyF12,
 }
 
+var verbose = flag.Bool("verbose", false, "print all communication with the keyboard")
+
 func main() {
+	flag.Parse()
 	ctx := context.Background()
 
-	kb, err := dkb4q.Open()
+	disp, closeDisplay, err := openDisplay(ctx)
 	if err != nil {
 		log.Fatal(err)
 	}
-	defer kb.Close()
+	defer closeDisplay()
 
 	var state cpuState
 	if err := state.update(); err != nil {
@@ -92,12 +99,54 @@ func main() {
 			keyState = append(keyState, ks)
 		}
 
-		if err := kb.SetState(ctx, keyState...); err != nil {
+		if err := disp.Apply(ctx, keyState...); err != nil {
 			log.Fatal(err)
 		}
 	}
 }
 
+// display shows the state of keys.
+type display interface {
+	Apply(ctx context.Context, states ...dkb4q.State) error
+}
+
+// openDisplay opens the first supported keyboard. A 4Q is driven via a
+// reconnecting dkb4q.Conn, only sending keys whose color changed since the
+// last update. Other models are driven via the driver.Keyboard interface.
+func openDisplay(ctx context.Context) (display, func() error, error) {
+	var opts []driver.Option
+	if *verbose {
+		opts = append(opts, driver.Tracing(os.Stdout))
+	}
+
+	kb, err := driver.OpenAny(ctx, opts...)
+	if err != nil {
+		return nil, nil, err
+	}
+	if _, ok := kb.(*dkb4q.Keyboard); !ok {
+		return driverDisplay{kb}, kb.Close, nil
+	}
+
+	// The Conn opens the 4Q itself, and again after it has been unplugged.
+	kb.Close()
+	conn := cli.NewConn(*verbose)
+	return dkb4q.NewFrame(conn), conn.Close, nil
+}
+
+// driverDisplay shows the idle color of keys on any supported model.
+type driverDisplay struct {
+	kb driver.Keyboard
+}
+
+func (d driverDisplay) Apply(ctx context.Context, states ...dkb4q.State) error {
+	for _, s := range states {
+		if err := d.kb.SetColor(ctx, s.IdleColor, s.ID.String()); err != nil {
+			return err
+		}
+	}
+	return d.kb.Commit(ctx)
+}
+
 type cpuState struct {
 	counter []uint64
 	rate    []float64
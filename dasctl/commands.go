@@ -0,0 +1,209 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"image/color"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/octo/das/dkb4q"
+	"github.com/octo/das/driver"
+	"github.com/octo/das/profile"
+)
+
+func runSet(ctx context.Context, args []string, w io.Writer) error {
+	fs := flag.NewFlagSet("set", flag.ContinueOnError)
+	effect := fs.String("effect", driver.EffectSetColor, "idle effect")
+	active := fs.String("active", "", "active effect, shown when the key is pressed (4Q only)")
+	activeColor := fs.String("active-color", "#FFFFFF", "color of the active effect")
+
+	pos, err := parseArgs(fs, args, 2)
+	if err != nil {
+		return err
+	}
+	keys := strings.Split(pos[0], ",")
+	c, err := profile.ParseColor(pos[1])
+	if err != nil {
+		return err
+	}
+
+	kb, err := openKeyboard(ctx)
+	if err != nil {
+		return err
+	}
+	defer kb.Close()
+
+	if *active == "" {
+		if err := kb.SetEffect(ctx, *effect, c, keys...); err != nil {
+			return err
+		}
+		return kb.Commit(ctx)
+	}
+
+	kb4q, ok := kb.(*dkb4q.Keyboard)
+	if !ok {
+		return fmt.Errorf("active effects are only supported by the 4Q")
+	}
+	return setActive(ctx, kb4q, keys, *effect, c, *active, *activeColor)
+}
+
+func setActive(ctx context.Context, kb dkb4q.StateSetter, keys []string, effect string, c color.NRGBA, active, activeColor string) error {
+	idle, err := dkb4q.ParseIdleEffect(effect)
+	if err != nil {
+		return err
+	}
+	newActive, ok := activeEffects[active]
+	if !ok {
+		return fmt.Errorf("unknown active effect %q", active)
+	}
+	ac, err := profile.ParseColor(activeColor)
+	if err != nil {
+		return err
+	}
+
+	var states []dkb4q.State
+	for _, name := range keys {
+		id, err := dkb4q.KeyByName(name)
+		if err != nil {
+			return err
+		}
+		states = append(states, dkb4q.State{
+			ID:           id,
+			IdleEffect:   idle,
+			IdleColor:    c,
+			ActiveEffect: newActive(),
+			ActiveColor:  ac,
+		})
+	}
+
+	return kb.SetState(ctx, states...)
+}
+
+// activeEffects maps the names accepted by "set -active" to active effects.
+var activeEffects = map[string]func() dkb4q.ActiveEffect{
+	"none":      func() dkb4q.ActiveEffect { return dkb4q.None },
+	"set_color": func() dkb4q.ActiveEffect { return dkb4q.SetColorActive() },
+	"blink":     func() dkb4q.ActiveEffect { return dkb4q.BlinkActive() },
+	"breathe":   func() dkb4q.ActiveEffect { return dkb4q.BreatheActive() },
+}
+
+func runFill(ctx context.Context, args []string, w io.Writer) error {
+	fs := flag.NewFlagSet("fill", flag.ContinueOnError)
+	effect := fs.String("effect", driver.EffectSetColor, "idle effect")
+
+	pos, err := parseArgs(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	c, err := profile.ParseColor(pos[0])
+	if err != nil {
+		return err
+	}
+
+	return fill(ctx, *effect, c)
+}
+
+func runClear(ctx context.Context, args []string, w io.Writer) error {
+	fs := flag.NewFlagSet("clear", flag.ContinueOnError)
+	if _, err := parseArgs(fs, args, 0); err != nil {
+		return err
+	}
+
+	return fill(ctx, driver.EffectSetColor, color.NRGBA{})
+}
+
+// fill sets all named keys to the same effect and color.
+func fill(ctx context.Context, effect string, c color.NRGBA) error {
+	kb, err := openKeyboard(ctx)
+	if err != nil {
+		return err
+	}
+	defer kb.Close()
+
+	if err := kb.SetEffect(ctx, effect, c, kb.Keys()...); err != nil {
+		return err
+	}
+	return kb.Commit(ctx)
+}
+
+// runApply applies a profile, see package profile. On models other than the
+// 4Q, only idle effects are supported.
+func runApply(ctx context.Context, args []string, w io.Writer) error {
+	fs := flag.NewFlagSet("apply", flag.ContinueOnError)
+	pos, err := parseArgs(fs, args, 1)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Open(pos[0])
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	p, err := profile.Read(f)
+	if err != nil {
+		return fmt.Errorf("parsing %s: %w", pos[0], err)
+	}
+
+	kb, err := openKeyboard(ctx)
+	if err != nil {
+		return err
+	}
+	defer kb.Close()
+
+	if kb4q, ok := kb.(*dkb4q.Keyboard); ok {
+		states, err := p.States()
+		if err != nil {
+			return fmt.Errorf("%s: %w", pos[0], err)
+		}
+		return kb4q.SetState(ctx, states...)
+	}
+
+	// later entries override earlier ones, like in Profile.States.
+	for i, e := range p.Keys {
+		if e.ActiveEffect != dkb4q.None {
+			return fmt.Errorf("%s: entry %d: active effects are only supported by the 4Q", pos[0], i)
+		}
+		if err := kb.SetEffect(ctx, e.IdleEffect.String(), color.NRGBA(e.IdleColor), p.KeyNames(e.Keys)...); err != nil {
+			return fmt.Errorf("%s: entry %d: %w", pos[0], i, err)
+		}
+	}
+	return kb.Commit(ctx)
+}
+
+func runKeys(ctx context.Context, args []string, w io.Writer) error {
+	fs := flag.NewFlagSet("keys", flag.ContinueOnError)
+	if _, err := parseArgs(fs, args, 0); err != nil {
+		return err
+	}
+
+	kb, err := openKeyboard(ctx)
+	if err != nil {
+		return err
+	}
+	defer kb.Close()
+
+	for _, name := range kb.Keys() {
+		fmt.Fprintln(w, name)
+	}
+	return nil
+}
+
+func runListDevices(ctx context.Context, args []string, w io.Writer) error {
+	fs := flag.NewFlagSet("list-devices", flag.ContinueOnError)
+	if _, err := parseArgs(fs, args, 0); err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "INDEX\tMODEL\tPRODUCT\tREVISION\tINTERFACE")
+	for _, info := range driver.List() {
+		fmt.Fprintf(tw, "%d\t%v\t%#04x\t%#04x\t%d\n", info.Index, driver.ModelOf(info), info.Product, info.Revision, info.Interface)
+	}
+	return tw.Flush()
+}
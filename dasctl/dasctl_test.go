@@ -0,0 +1,191 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"image/color"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/octo/das/dkb4q"
+	"github.com/octo/das/dkb4q/fake"
+	"github.com/octo/das/driver"
+)
+
+// keepOpen is a Simulator that is not closed when the keyboard is closed,
+// so that it can be opened again by the next command.
+type keepOpen struct {
+	*fake.Simulator
+}
+
+func (keepOpen) Close() {}
+
+// withSimulator replaces openKeyboard with a 4Q talking to a simulator.
+func withSimulator(t *testing.T) *fake.Simulator {
+	t.Helper()
+
+	sim := fake.NewSimulator()
+	orig := openKeyboard
+	openKeyboard = func(context.Context) (driver.Keyboard, error) {
+		kb := dkb4q.New(keepOpen{sim})
+		return &kb, nil
+	}
+	t.Cleanup(func() { openKeyboard = orig })
+
+	return sim
+}
+
+func TestSet(t *testing.T) {
+	red := color.NRGBA{R: 0xFF, A: 0xFF}
+	blue := color.NRGBA{B: 0xFF, A: 0xFF}
+
+	cases := []struct {
+		name string
+		args []string
+		want map[dkb4q.Key]fake.KeyState
+	}{
+		{
+			name: "color",
+			args: []string{"Esc,F1", "#FF0000"},
+			want: map[dkb4q.Key]fake.KeyState{
+				dkb4q.KeyEsc: {IdleEffect: uint8(dkb4q.SetColor), IdleColor: red, ActiveColor: color.NRGBA{A: 0xFF}},
+				dkb4q.KeyF1:  {IdleEffect: uint8(dkb4q.SetColor), IdleColor: red, ActiveColor: color.NRGBA{A: 0xFF}},
+			},
+		},
+		{
+			name: "flags after arguments",
+			args: []string{"F2", "#FF0000", "--effect", "breathe"},
+			want: map[dkb4q.Key]fake.KeyState{
+				dkb4q.KeyF2: {IdleEffect: uint8(dkb4q.Breathe), IdleColor: red, ActiveColor: color.NRGBA{A: 0xFF}},
+			},
+		},
+		{
+			name: "active effect",
+			args: []string{"-active", "blink", "-active-color", "#0000FF", "F3", "#FF0000"},
+			want: map[dkb4q.Key]fake.KeyState{
+				dkb4q.KeyF3: {IdleEffect: uint8(dkb4q.SetColor), IdleColor: red, ActiveEffect: uint8(dkb4q.Blink), ActiveColor: blue, ActiveArgs: [3]byte{0x01, 0xF4, 0x03}},
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			sim := withSimulator(t)
+
+			if err := runSet(context.Background(), tc.args, ioutil.Discard); err != nil {
+				t.Fatalf("runSet(%q) = %v", tc.args, err)
+			}
+
+			for id, want := range tc.want {
+				got, ok := sim.Committed(uint8(id))
+				if !ok {
+					t.Errorf("key %v has not been committed", id)
+					continue
+				}
+				if diff := cmp.Diff(want, got); diff != "" {
+					t.Errorf("key %v differs (+got/-want):\n%s", id, diff)
+				}
+			}
+		})
+	}
+}
+
+func TestSet_Errors(t *testing.T) {
+	for _, args := range [][]string{
+		{"F1"},
+		{"F1", "red"},
+		{"NoSuchKey", "#FF0000"},
+		{"F1", "#FF0000", "-effect", "sparkle"},
+		{"F1", "#FF0000", "-active", "sparkle"},
+	} {
+		withSimulator(t)
+		if err := runSet(context.Background(), args, ioutil.Discard); err == nil {
+			t.Errorf("runSet(%q) succeeded, want error", args)
+		}
+	}
+}
+
+func TestFillClear(t *testing.T) {
+	sim := withSimulator(t)
+	ctx := context.Background()
+
+	if err := runFill(ctx, []string{"#00FF00"}, ioutil.Discard); err != nil {
+		t.Fatal(err)
+	}
+	for _, k := range dkb4q.Keys() {
+		got, _ := sim.Committed(uint8(k))
+		if want := (color.NRGBA{G: 0xFF, A: 0xFF}); got.IdleColor != want {
+			t.Fatalf("key %v: IdleColor = %v, want %v", k, got.IdleColor, want)
+		}
+	}
+
+	if err := runClear(ctx, nil, ioutil.Discard); err != nil {
+		t.Fatal(err)
+	}
+	for _, k := range dkb4q.Keys() {
+		got, _ := sim.Committed(uint8(k))
+		if want := (color.NRGBA{A: 0xFF}); got.IdleColor != want {
+			t.Fatalf("key %v: IdleColor = %v, want %v", k, got.IdleColor, want)
+		}
+	}
+}
+
+func TestApply(t *testing.T) {
+	sim := withSimulator(t)
+
+	path := filepath.Join(t.TempDir(), "profile.json")
+	data := `{
+  "groups": {"fkeys": ["F1", "F2"]},
+  "keys": [
+    {"keys": ["Esc"], "idleEffect": "blink", "idleColor": "#FF0000"},
+    {"keys": ["fkeys"], "idleEffect": "set_color", "idleColor": "#0000FF", "activeEffect": {"effect": "breathe", "cycleCount": 5}, "activeColor": "#FF0000"}
+  ]
+}`
+	if err := ioutil.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := runApply(context.Background(), []string{path}, ioutil.Discard); err != nil {
+		t.Fatalf("runApply() = %v", err)
+	}
+
+	want := map[dkb4q.Key]fake.KeyState{
+		dkb4q.KeyEsc: {IdleEffect: uint8(dkb4q.Blink), IdleColor: color.NRGBA{R: 0xFF, A: 0xFF}, ActiveColor: color.NRGBA{A: 0xFF}},
+		dkb4q.KeyF1:  {IdleEffect: uint8(dkb4q.SetColor), IdleColor: color.NRGBA{B: 0xFF, A: 0xFF}, ActiveEffect: uint8(dkb4q.Breathe), ActiveColor: color.NRGBA{R: 0xFF, A: 0xFF}, ActiveArgs: [3]byte{0x03, 0xE8, 0x05}},
+		dkb4q.KeyF2:  {IdleEffect: uint8(dkb4q.SetColor), IdleColor: color.NRGBA{B: 0xFF, A: 0xFF}, ActiveEffect: uint8(dkb4q.Breathe), ActiveColor: color.NRGBA{R: 0xFF, A: 0xFF}, ActiveArgs: [3]byte{0x03, 0xE8, 0x05}},
+	}
+	for id, want := range want {
+		got, _ := sim.Committed(uint8(id))
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Errorf("key %v differs (+got/-want):\n%s", id, diff)
+		}
+	}
+	if got := sim.Commits(); got != 1 {
+		t.Errorf("Commits() = %d, want 1", got)
+	}
+
+	if err := runApply(context.Background(), []string{filepath.Join(t.TempDir(), "missing.json")}, ioutil.Discard); !os.IsNotExist(err) {
+		t.Errorf("runApply(missing) = %v, want not exist error", err)
+	}
+}
+
+func TestKeys(t *testing.T) {
+	withSimulator(t)
+
+	var buf bytes.Buffer
+	if err := runKeys(context.Background(), nil, &buf); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if got, want := len(lines), len(dkb4q.Keys()); got != want {
+		t.Errorf("got %d keys, want %d", got, want)
+	}
+	if got, want := lines[0], "LeftCtrl"; got != want {
+		t.Errorf("first line = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,107 @@
+// dasctl controls "Das Keyboard" keyboards from the command line. All models
+// supported by package driver, currently the 4Q, can be used.
+//
+// Usage:
+//
+//	dasctl set <keys> <color> [-effect breathe] [-active blink] [-active-color <color>]
+//	dasctl fill <color> [-effect breathe]
+//	dasctl clear
+//	dasctl apply <profile>
+//	dasctl keys
+//	dasctl list-devices
+//
+// Keys are comma separated key names, e.g. "Esc,F1", see "dasctl keys".
+// Colors are given as "#RRGGBB". Effects are "set_color", "breathe", "blink"
+// and "color_cycle"; active effects, i.e. the effect shown when a key is
+// pressed, are only supported by the 4Q. The profile format read by "apply"
+// is described in package profile.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/octo/das/driver"
+)
+
+// command is a dasctl subcommand.
+type command struct {
+	name  string
+	usage string
+	run   func(ctx context.Context, args []string, w io.Writer) error
+}
+
+var commands = []command{
+	{"set", "<keys> <color> [-effect <effect>] [-active <effect>] [-active-color <color>]", runSet},
+	{"fill", "<color> [-effect <effect>]", runFill},
+	{"clear", "", runClear},
+	{"apply", "<profile>", runApply},
+	{"keys", "", runKeys},
+	{"list-devices", "", runListDevices},
+}
+
+// openKeyboard opens the keyboard. It is replaced in tests.
+var openKeyboard = func(ctx context.Context) (driver.Keyboard, error) {
+	return driver.OpenAny(ctx)
+}
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+
+	if flag.NArg() < 1 {
+		usage()
+		os.Exit(2)
+	}
+
+	name, args := flag.Arg(0), flag.Args()[1:]
+	for _, cmd := range commands {
+		if cmd.name != name {
+			continue
+		}
+		if err := cmd.run(context.Background(), args, os.Stdout); err != nil {
+			fmt.Fprintf(os.Stderr, "dasctl %s: %v\n", name, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	fmt.Fprintf(os.Stderr, "dasctl: unknown command %q\n", name)
+	usage()
+	os.Exit(2)
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "Usage:")
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  dasctl %s %s\n", cmd.name, cmd.usage)
+	}
+}
+
+// parseArgs parses flags and positional arguments, which may be mixed, e.g.
+// "F1 -effect breathe #FF0000". It returns an error unless exactly n
+// positional arguments are given.
+func parseArgs(fs *flag.FlagSet, args []string, n int) ([]string, error) {
+	fs.SetOutput(io.Discard)
+
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			break
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+
+	if len(positional) != n {
+		return nil, fmt.Errorf("got %d arguments, want %d", len(positional), n)
+	}
+	return positional, nil
+}
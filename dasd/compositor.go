@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"image/color"
+	"sync"
+
+	"github.com/octo/das/dkb4q"
+)
+
+// layer is a set of keys owned by one client, with the states the client set
+// for them.
+type layer struct {
+	id       int
+	name     string
+	priority int
+	keys     map[dkb4q.Key]bool
+	states   map[dkb4q.Key]dkb4q.State
+}
+
+// compositor composites the layers of all clients into the state of the
+// keyboard. For each key, the state of the layer with the highest priority
+// that owns the key and has set a state for it is shown. Layers with the same
+// priority cannot own the same key. Keys not set by any layer are turned off.
+//
+// compositor is safe for concurrent use.
+type compositor struct {
+	// sendMu serializes sending to the keyboard, which may take up to
+	// requestTimeout. mu guards the layers and is not held while sending.
+	sendMu sync.Mutex
+	frame  *dkb4q.Frame
+
+	mu     sync.Mutex
+	layers map[int]*layer
+	lastID int
+}
+
+func newCompositor(kb dkb4q.StateSetter) *compositor {
+	return &compositor{
+		frame:  dkb4q.NewFrame(kb),
+		layers: make(map[int]*layer),
+	}
+}
+
+// addLayer registers a new layer owning keys and returns its ID. The layer
+// is transparent until states are set with setStates. An error is returned if
+// a layer with the same priority already owns one of the keys.
+func (c *compositor) addLayer(name string, priority int, keys []dkb4q.Key) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, other := range c.layers {
+		if other.priority != priority {
+			continue
+		}
+		for _, k := range keys {
+			if other.keys[k] {
+				return 0, fmt.Errorf("key %v is already owned by layer %q with priority %d", k, other.name, priority)
+			}
+		}
+	}
+
+	c.lastID++
+	l := &layer{
+		id:       c.lastID,
+		name:     name,
+		priority: priority,
+		keys:     make(map[dkb4q.Key]bool),
+		states:   make(map[dkb4q.Key]dkb4q.State),
+	}
+	for _, k := range keys {
+		l.keys[k] = true
+	}
+	c.layers[l.id] = l
+
+	return l.id, nil
+}
+
+// setStates sets the states of keys in a layer. All keys must be owned by the
+// layer.
+func (c *compositor) setStates(ctx context.Context, id int, states ...dkb4q.State) error {
+	keys, err := c.updateLayer(id, states)
+	if err != nil {
+		return err
+	}
+	return c.apply(ctx, keys)
+}
+
+// updateLayer stores states in a layer and returns the keys that changed.
+func (c *compositor) updateLayer(id int, states []dkb4q.State) ([]dkb4q.Key, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	l, ok := c.layers[id]
+	if !ok {
+		return nil, fmt.Errorf("no such layer: %d", id)
+	}
+
+	for _, s := range states {
+		if !l.keys[s.ID] {
+			return nil, fmt.Errorf("layer %q does not own key %v", l.name, s.ID)
+		}
+	}
+
+	keys := make([]dkb4q.Key, 0, len(states))
+	for _, s := range states {
+		l.states[s.ID] = s
+		keys = append(keys, s.ID)
+	}
+	return keys, nil
+}
+
+// removeLayer removes a layer. Its keys fall back to the layers below.
+func (c *compositor) removeLayer(ctx context.Context, id int) error {
+	c.mu.Lock()
+	l, ok := c.layers[id]
+	delete(c.layers, id)
+	c.mu.Unlock()
+
+	if !ok {
+		return fmt.Errorf("no such layer: %d", id)
+	}
+
+	keys := make([]dkb4q.Key, 0, len(l.states))
+	for k := range l.states {
+		keys = append(keys, k)
+	}
+
+	return c.apply(ctx, keys)
+}
+
+// apply sends the composited state of keys to the keyboard. The states are
+// composited after acquiring c.sendMu, so that concurrent calls cannot send
+// an outdated state last.
+func (c *compositor) apply(ctx context.Context, keys []dkb4q.Key) error {
+	c.sendMu.Lock()
+	defer c.sendMu.Unlock()
+
+	c.mu.Lock()
+	states := make([]dkb4q.State, 0, len(keys))
+	for _, k := range keys {
+		states = append(states, c.composite(k))
+	}
+	c.mu.Unlock()
+
+	return c.frame.Apply(ctx, states...)
+}
+
+// composite returns the state shown for key k. The caller must hold c.mu.
+func (c *compositor) composite(k dkb4q.Key) dkb4q.State {
+	var top *layer
+	for _, l := range c.layers {
+		if _, ok := l.states[k]; !ok {
+			continue
+		}
+		if top == nil || l.priority > top.priority {
+			top = l
+		}
+	}
+
+	if top == nil {
+		return off(k)
+	}
+	return top.states[k]
+}
+
+// off returns the state of a key that has been turned off.
+func off(k dkb4q.Key) dkb4q.State {
+	return dkb4q.State{
+		ID:           k,
+		IdleEffect:   dkb4q.SetColor,
+		IdleColor:    color.NRGBA{},
+		ActiveEffect: dkb4q.None,
+	}
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"image/color"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/octo/das/dkb4q"
+	"github.com/octo/das/dkb4q/fake"
+)
+
+var (
+	red   = color.NRGBA{R: 0xFF, A: 0xFF}
+	blue  = color.NRGBA{B: 0xFF, A: 0xFF}
+	black = color.NRGBA{A: 0xFF}
+)
+
+func colorState(k dkb4q.Key, c color.NRGBA) dkb4q.State {
+	return dkb4q.State{ID: k, IdleEffect: dkb4q.SetColor, IdleColor: c, ActiveEffect: dkb4q.None}
+}
+
+// checkColors checks the committed idle colors of keys.
+func checkColors(t *testing.T, sim *fake.Simulator, want map[dkb4q.Key]color.NRGBA) {
+	t.Helper()
+
+	got := make(map[dkb4q.Key]color.NRGBA)
+	for k := range want {
+		ks, ok := sim.Committed(uint8(k))
+		if !ok {
+			continue
+		}
+		got[k] = ks.IdleColor
+	}
+
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("colors differ (+got/-want):\n%s", diff)
+	}
+}
+
+func mustAddLayer(t *testing.T, c *compositor, name string, priority int, keys []dkb4q.Key) int {
+	t.Helper()
+
+	id, err := c.addLayer(name, priority, keys)
+	if err != nil {
+		t.Fatalf("addLayer(%q) = %v", name, err)
+	}
+	return id
+}
+
+func TestCompositor(t *testing.T) {
+	ctx := context.Background()
+	sim := fake.NewSimulator()
+	kb := dkb4q.New(sim)
+	defer kb.Close()
+
+	c := newCompositor(&kb)
+
+	cpu := mustAddLayer(t, c, "cpu", 10, []dkb4q.Key{dkb4q.KeyF1, dkb4q.KeyF2})
+	alert := mustAddLayer(t, c, "alert", 100, []dkb4q.Key{dkb4q.KeyF2, dkb4q.KeyEsc})
+
+	if err := c.setStates(ctx, cpu, colorState(dkb4q.KeyF1, blue), colorState(dkb4q.KeyF2, blue)); err != nil {
+		t.Fatalf("setStates(cpu) = %v", err)
+	}
+	checkColors(t, sim, map[dkb4q.Key]color.NRGBA{
+		dkb4q.KeyF1: blue,
+		dkb4q.KeyF2: blue,
+	})
+
+	// the alert layer has a higher priority.
+	if err := c.setStates(ctx, alert, colorState(dkb4q.KeyF2, red), colorState(dkb4q.KeyEsc, red)); err != nil {
+		t.Fatalf("setStates(alert) = %v", err)
+	}
+	checkColors(t, sim, map[dkb4q.Key]color.NRGBA{
+		dkb4q.KeyF1:  blue,
+		dkb4q.KeyF2:  red,
+		dkb4q.KeyEsc: red,
+	})
+
+	// updates of lower layers are not shown.
+	commits := sim.Commits()
+	if err := c.setStates(ctx, cpu, colorState(dkb4q.KeyF2, black)); err != nil {
+		t.Fatalf("setStates(cpu) = %v", err)
+	}
+	if got := sim.Commits(); got != commits {
+		t.Errorf("Commits() = %d, want %d: the covered key has been sent", got, commits)
+	}
+
+	// keys fall back to the layer below, or are turned off.
+	if err := c.removeLayer(ctx, alert); err != nil {
+		t.Fatalf("removeLayer(alert) = %v", err)
+	}
+	checkColors(t, sim, map[dkb4q.Key]color.NRGBA{
+		dkb4q.KeyF1:  blue,
+		dkb4q.KeyF2:  black,
+		dkb4q.KeyEsc: black,
+	})
+}
+
+func TestCompositor_Ownership(t *testing.T) {
+	ctx := context.Background()
+	kb := dkb4q.New(fake.NewSimulator())
+	defer kb.Close()
+
+	c := newCompositor(&kb)
+	id := mustAddLayer(t, c, "cpu", 10, []dkb4q.Key{dkb4q.KeyF1})
+
+	if err := c.setStates(ctx, id, colorState(dkb4q.KeyEsc, red)); err == nil {
+		t.Error("setStates() with a key not owned by the layer succeeded, want error")
+	}
+	if err := c.setStates(ctx, id+1, colorState(dkb4q.KeyF1, red)); err == nil {
+		t.Error("setStates() with an unknown layer succeeded, want error")
+	}
+}
+
+func TestCompositor_SamePriority(t *testing.T) {
+	kb := dkb4q.New(fake.NewSimulator())
+	defer kb.Close()
+
+	c := newCompositor(&kb)
+	mustAddLayer(t, c, "first", 10, []dkb4q.Key{dkb4q.KeyF1})
+
+	if _, err := c.addLayer("second", 10, []dkb4q.Key{dkb4q.KeyF2, dkb4q.KeyF1}); err == nil {
+		t.Error("addLayer() with a key owned by a layer of the same priority succeeded, want error")
+	}
+	mustAddLayer(t, c, "third", 20, []dkb4q.Key{dkb4q.KeyF1})
+}
+
+// blockingSetter blocks SetState until release is closed.
+type blockingSetter struct {
+	called  chan struct{}
+	release chan struct{}
+}
+
+func (b *blockingSetter) SetState(ctx context.Context, states ...dkb4q.State) error {
+	b.called <- struct{}{}
+	<-b.release
+	return nil
+}
+
+func TestCompositor_SlowKeyboard(t *testing.T) {
+	ctx := context.Background()
+	kb := &blockingSetter{
+		called:  make(chan struct{}),
+		release: make(chan struct{}),
+	}
+
+	c := newCompositor(kb)
+	cpu := mustAddLayer(t, c, "cpu", 10, []dkb4q.Key{dkb4q.KeyF1})
+
+	done := make(chan error)
+	go func() {
+		done <- c.setStates(ctx, cpu, colorState(dkb4q.KeyF1, red))
+	}()
+	<-kb.called
+
+	// Other clients can register while the keyboard is busy.
+	mustAddLayer(t, c, "alert", 100, []dkb4q.Key{dkb4q.KeyEsc})
+
+	close(kb.release)
+	if err := <-done; err != nil {
+		t.Errorf("setStates() = %v", err)
+	}
+}
@@ -0,0 +1,66 @@
+// dasd owns the keyboard and lets multiple clients share it. Clients connect
+// to a Unix socket and register layers: a priority and the keys the layer
+// owns. The layers are composited into the keyboard's state, i.e. each key
+// shows the state of the highest priority layer that set it. Layers of the
+// same priority cannot own the same key. When a client disconnects, its keys
+// fall back to the layers below.
+//
+// The protocol consists of JSON objects, one per line. For example:
+//
+//	$ nc -U /tmp/dasd.sock
+//	{"op": "register", "layer": "alert", "priority": 100, "keys": ["Esc"]}
+//	{}
+//	{"op": "set", "layer": "alert", "states": [{"key": "Esc", "effect": "blink", "color": "#FF0000"}]}
+//	{}
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+
+	"github.com/octo/das/internal/cli"
+)
+
+var (
+	socket  = flag.String("socket", filepath.Join(os.TempDir(), "dasd.sock"), "path of the Unix socket to listen on")
+	verbose = flag.Bool("verbose", false, "print all communication with the keyboard")
+)
+
+func main() {
+	flag.Parse()
+	ctx := context.Background()
+
+	conn := cli.NewConn(*verbose)
+	defer conn.Close()
+
+	// Remove a stale socket left behind by a previous instance.
+	if err := os.Remove(*socket); err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Fatal(err)
+	}
+
+	l, err := net.Listen("unix", *socket)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer l.Close()
+	log.Printf("listening on %s", *socket)
+
+	comp := newCompositor(conn)
+	for {
+		c, err := l.Accept()
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		go func() {
+			if err := serve(ctx, c, comp); err != nil {
+				log.Printf("client: %v", err)
+			}
+		}()
+	}
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"time"
+
+	"github.com/octo/das/dkb4q"
+	"github.com/octo/das/profile"
+)
+
+// request is sent by clients, one JSON object per line. Ops are:
+//
+//	{"op": "register", "layer": "cpu", "priority": 10, "keys": ["F1", "F2"]}
+//	{"op": "set", "layer": "cpu", "states": [{"key": "F1", "effect": "breathe", "color": "#FF0000"}]}
+//	{"op": "unregister", "layer": "cpu"}
+//
+// Key names are the ones accepted by dkb4q.KeyByName, effects the ones
+// accepted by dkb4q.ParseIdleEffect. The effect defaults to "set_color".
+type request struct {
+	Op       string     `json:"op"`
+	Layer    string     `json:"layer"`
+	Priority int        `json:"priority,omitempty"`
+	Keys     []string   `json:"keys,omitempty"`
+	States   []keyState `json:"states,omitempty"`
+}
+
+type keyState struct {
+	Key    string `json:"key"`
+	Effect string `json:"effect,omitempty"`
+	Color  string `json:"color"`
+}
+
+// response is sent for every request, one JSON object per line. Error is
+// empty if the request succeeded.
+type response struct {
+	Error string `json:"error,omitempty"`
+}
+
+// requestTimeout is the time a request may take, including reconnecting to
+// the keyboard.
+const requestTimeout = 10 * time.Second
+
+// session handles the requests of one client. All layers registered by the
+// client are removed when the connection is closed.
+type session struct {
+	comp   *compositor
+	layers map[string]int
+}
+
+func serve(ctx context.Context, conn io.ReadWriteCloser, comp *compositor) error {
+	defer conn.Close()
+
+	s := &session{
+		comp:   comp,
+		layers: make(map[string]int),
+	}
+	defer s.close(ctx)
+
+	scanner := bufio.NewScanner(conn)
+	enc := json.NewEncoder(conn)
+	for scanner.Scan() {
+		var res response
+		if err := s.handle(ctx, scanner.Bytes()); err != nil {
+			res.Error = err.Error()
+		}
+		if err := enc.Encode(res); err != nil {
+			return err
+		}
+	}
+
+	return scanner.Err()
+}
+
+func (s *session) handle(ctx context.Context, line []byte) error {
+	var req request
+	if err := json.Unmarshal(line, &req); err != nil {
+		return fmt.Errorf("decoding request: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+
+	switch req.Op {
+	case "register":
+		return s.register(req)
+	case "set":
+		return s.set(ctx, req)
+	case "unregister":
+		return s.unregister(ctx, req)
+	default:
+		return fmt.Errorf("unknown op %q", req.Op)
+	}
+}
+
+func (s *session) register(req request) error {
+	if _, ok := s.layers[req.Layer]; ok {
+		return fmt.Errorf("layer %q is already registered", req.Layer)
+	}
+
+	keys := make([]dkb4q.Key, 0, len(req.Keys))
+	for _, name := range req.Keys {
+		k, err := dkb4q.KeyByName(name)
+		if err != nil {
+			return err
+		}
+		keys = append(keys, k)
+	}
+
+	id, err := s.comp.addLayer(req.Layer, req.Priority, keys)
+	if err != nil {
+		return err
+	}
+	s.layers[req.Layer] = id
+	return nil
+}
+
+func (s *session) set(ctx context.Context, req request) error {
+	id, ok := s.layers[req.Layer]
+	if !ok {
+		return fmt.Errorf("layer %q is not registered", req.Layer)
+	}
+
+	states := make([]dkb4q.State, 0, len(req.States))
+	for _, ks := range req.States {
+		st, err := ks.state()
+		if err != nil {
+			return err
+		}
+		states = append(states, st)
+	}
+
+	return s.comp.setStates(ctx, id, states...)
+}
+
+func (s *session) unregister(ctx context.Context, req request) error {
+	id, ok := s.layers[req.Layer]
+	if !ok {
+		return fmt.Errorf("layer %q is not registered", req.Layer)
+	}
+	delete(s.layers, req.Layer)
+
+	return s.comp.removeLayer(ctx, id)
+}
+
+// close removes all layers of the session.
+func (s *session) close(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+
+	for name, id := range s.layers {
+		if err := s.comp.removeLayer(ctx, id); err != nil {
+			log.Printf("removing layer %q: %v", name, err)
+		}
+		delete(s.layers, name)
+	}
+}
+
+func (ks keyState) state() (dkb4q.State, error) {
+	k, err := dkb4q.KeyByName(ks.Key)
+	if err != nil {
+		return dkb4q.State{}, err
+	}
+
+	effect := dkb4q.SetColor
+	if ks.Effect != "" {
+		effect, err = dkb4q.ParseIdleEffect(ks.Effect)
+		if err != nil {
+			return dkb4q.State{}, err
+		}
+	}
+
+	c, err := profile.ParseColor(ks.Color)
+	if err != nil {
+		return dkb4q.State{}, err
+	}
+
+	return dkb4q.State{
+		ID:           k,
+		IdleEffect:   effect,
+		IdleColor:    c,
+		ActiveEffect: dkb4q.None,
+	}, nil
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"image/color"
+	"net"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/octo/das/dkb4q"
+	"github.com/octo/das/dkb4q/fake"
+)
+
+// client is the client side of a session.
+type client struct {
+	t       *testing.T
+	conn    net.Conn
+	scanner *bufio.Scanner
+	done    chan error
+}
+
+func newClient(t *testing.T, comp *compositor) *client {
+	t.Helper()
+
+	clientConn, serverConn := net.Pipe()
+	c := &client{
+		t:       t,
+		conn:    clientConn,
+		scanner: bufio.NewScanner(clientConn),
+		done:    make(chan error, 1),
+	}
+	go func() {
+		c.done <- serve(context.Background(), serverConn, comp)
+	}()
+	return c
+}
+
+func (c *client) do(req request) response {
+	c.t.Helper()
+
+	data, err := json.Marshal(req)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	if _, err := c.conn.Write(append(data, '\n')); err != nil {
+		c.t.Fatal(err)
+	}
+
+	if !c.scanner.Scan() {
+		c.t.Fatalf("reading response: %v", c.scanner.Err())
+	}
+	var res response
+	if err := json.Unmarshal(c.scanner.Bytes(), &res); err != nil {
+		c.t.Fatal(err)
+	}
+	return res
+}
+
+// close disconnects the client and waits for the session to end.
+func (c *client) close() {
+	c.conn.Close()
+	<-c.done
+}
+
+func TestSession(t *testing.T) {
+	sim := fake.NewSimulator()
+	kb := dkb4q.New(sim)
+	defer kb.Close()
+	comp := newCompositor(&kb)
+
+	cpu := newClient(t, comp)
+	defer cpu.close()
+	alert := newClient(t, comp)
+
+	for _, tc := range []struct {
+		c       *client
+		req     request
+		wantErr bool
+	}{
+		{cpu, request{Op: "register", Layer: "cpu", Priority: 10, Keys: []string{"F1", "Esc"}}, false},
+		{cpu, request{Op: "set", Layer: "cpu", States: []keyState{{Key: "F1", Color: "#0000FF"}, {Key: "Esc", Color: "#0000FF"}}}, false},
+		{alert, request{Op: "register", Layer: "alert", Priority: 100, Keys: []string{"Esc"}}, false},
+		{alert, request{Op: "set", Layer: "alert", States: []keyState{{Key: "Esc", Effect: "blink", Color: "#FF0000"}}}, false},
+		// errors
+		{alert, request{Op: "register", Layer: "alert", Keys: []string{"Esc"}}, true},
+		{alert, request{Op: "register", Layer: "invalid", Keys: []string{"NoSuchKey"}}, true},
+		{alert, request{Op: "register", Layer: "clash", Priority: 10, Keys: []string{"F1"}}, true},
+		{alert, request{Op: "set", Layer: "alert", States: []keyState{{Key: "F1", Color: "#FF0000"}}}, true},
+		{alert, request{Op: "set", Layer: "alert", States: []keyState{{Key: "Esc", Effect: "sparkle", Color: "#FF0000"}}}, true},
+		{alert, request{Op: "set", Layer: "unknown"}, true},
+		{alert, request{Op: "frobnicate"}, true},
+	} {
+		res := tc.c.do(tc.req)
+		if gotErr := res.Error != ""; gotErr != tc.wantErr {
+			t.Errorf("%+v: error %q, want error %v", tc.req, res.Error, tc.wantErr)
+		}
+	}
+
+	esc, _ := sim.Committed(uint8(dkb4q.KeyEsc))
+	want := fake.KeyState{IdleEffect: uint8(dkb4q.Blink), IdleColor: color.NRGBA{R: 0xFF, A: 0xFF}, ActiveColor: color.NRGBA{A: 0xFF}}
+	if diff := cmp.Diff(want, esc); diff != "" {
+		t.Errorf("Esc differs (+got/-want):\n%s", diff)
+	}
+
+	// Disconnecting the alert client reveals the cpu layer.
+	alert.close()
+
+	esc, _ = sim.Committed(uint8(dkb4q.KeyEsc))
+	want = fake.KeyState{IdleEffect: uint8(dkb4q.SetColor), IdleColor: color.NRGBA{B: 0xFF, A: 0xFF}, ActiveColor: color.NRGBA{A: 0xFF}}
+	if diff := cmp.Diff(want, esc); diff != "" {
+		t.Errorf("Esc after disconnect differs (+got/-want):\n%s", diff)
+	}
+}
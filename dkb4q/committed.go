@@ -0,0 +1,36 @@
+package dkb4q
+
+// LastCommitted returns the state of a key as last committed via kb. It
+// returns false if the key has not been set since the keyboard was opened.
+//
+// The state is not read from the keyboard: no command to read the state of a
+// key back from the 4Q has been found so far. The keyboard only acknowledges
+// the select (0x03), idle (0x08), active (0x04) and commit (0x0A) commands. In
+// particular, the lighting configured by other software cannot be determined.
+func (kb *Keyboard) LastCommitted(id Key) (State, bool) {
+	if err := kb.lock(); err != nil {
+		return State{}, false
+	}
+	defer kb.mu.Unlock()
+
+	s, ok := kb.committed[id]
+	return s, ok
+}
+
+// USBInfo returns the USB device information the keyboard was opened with. It
+// returns false for keyboards created with New.
+//
+// The firmware version is not available via the 0xEA/0x78 protocol; the USB
+// device descriptor's revision, DeviceInfo.Revision, usually changes with the
+// firmware though.
+func (kb *Keyboard) USBInfo() (DeviceInfo, bool) {
+	if err := kb.lock(); err != nil {
+		return DeviceInfo{}, false
+	}
+	defer kb.mu.Unlock()
+
+	if kb.info == nil {
+		return DeviceInfo{}, false
+	}
+	return *kb.info, true
+}
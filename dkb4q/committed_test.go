@@ -0,0 +1,37 @@
+package dkb4q
+
+import (
+	"context"
+	"image/color"
+	"testing"
+
+	"github.com/octo/das/dkb4q/fake"
+)
+
+func TestKeyboard_LastCommitted(t *testing.T) {
+	ctx := context.Background()
+
+	kb := New(fake.NewSimulator())
+	defer kb.Close()
+
+	if got, ok := kb.LastCommitted(KeyEsc); ok {
+		t.Errorf("LastCommitted(%v) = %+v, want not ok", KeyEsc, got)
+	}
+
+	want := State{ID: KeyEsc, IdleEffect: SetColor, IdleColor: color.NRGBA{R: 0xFF}}
+	if err := kb.SetState(ctx, want); err != nil {
+		t.Fatalf("SetState() = %v", err)
+	}
+
+	got, ok := kb.LastCommitted(KeyEsc)
+	if !ok {
+		t.Fatalf("LastCommitted(%v) = not ok, want ok", KeyEsc)
+	}
+	if got != want {
+		t.Errorf("LastCommitted(%v) = %+v, want %+v", KeyEsc, got, want)
+	}
+
+	if _, ok := kb.USBInfo(); ok {
+		t.Errorf("USBInfo() = ok, want not ok for a Keyboard created with New")
+	}
+}
@@ -0,0 +1,220 @@
+package dkb4q
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/octo/retry"
+)
+
+// ConnState is the state of a Conn.
+type ConnState int
+
+const (
+	// Disconnected means that there is no open connection to a keyboard.
+	Disconnected ConnState = iota
+	// Connected means that a keyboard has been opened.
+	Connected
+)
+
+func (s ConnState) String() string {
+	switch s {
+	case Disconnected:
+		return "disconnected"
+	case Connected:
+		return "connected"
+	default:
+		return fmt.Sprintf("ConnState(%d)", int(s))
+	}
+}
+
+// Conn is a managed connection to a keyboard which survives the keyboard
+// being unplugged or the host being suspended. When communicating with the
+// keyboard fails, Conn closes the keyboard and opens it again, with
+// exponential backoff. After reconnecting, the last known state of all keys
+// is applied again.
+//
+// Conn is safe for concurrent use.
+type Conn struct {
+	open     func() (Keyboard, error)
+	onChange func(ConnState, error)
+
+	// sendMu serializes calls to SetState. mu guards the fields below and
+	// is not held while waiting for the keyboard, so that State and Close
+	// do not block while SetState reconnects.
+	sendMu sync.Mutex
+
+	mu    sync.Mutex
+	kb    *Keyboard
+	known map[Key]State
+}
+
+// ConnOption is an option for NewConn.
+type ConnOption func(*Conn)
+
+// OnStateChange sets a function that is called whenever the connection state
+// changes. When disconnecting, err is the error that caused it. The function
+// is called synchronously and must not call any of Conn's methods.
+func OnStateChange(f func(state ConnState, err error)) ConnOption {
+	return func(c *Conn) {
+		c.onChange = f
+	}
+}
+
+// NewConn returns a new Conn using open to connect to the keyboard, for
+// example:
+//
+//	conn := dkb4q.NewConn(func() (dkb4q.Keyboard, error) {
+//		return dkb4q.Open()
+//	})
+//
+// The connection is established lazily, by the first call to SetState.
+func NewConn(open func() (Keyboard, error), opts ...ConnOption) *Conn {
+	c := &Conn{
+		open:  open,
+		known: make(map[Key]State),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// SetState sets the state of one or more keys, like Keyboard.SetState. If the
+// keyboard is not connected, or the connection fails, SetState (re)connects
+// and retries until it succeeds or ctx is cancelled. Errors reported by the
+// keyboard itself, i.e. *ResponseError, are returned immediately. Only states
+// that have been set successfully are applied again after reconnecting.
+func (c *Conn) SetState(ctx context.Context, states ...State) error {
+	c.sendMu.Lock()
+	defer c.sendMu.Unlock()
+
+	err := retry.Do(ctx, func(ctx context.Context) error {
+		kb, send, err := c.connect(states)
+		if err != nil {
+			return err
+		}
+
+		err = kb.SetState(ctx, send...)
+		if err == nil {
+			return nil
+		}
+
+		var resErr *ResponseError
+		if errors.As(err, &resErr) || ctx.Err() != nil {
+			return retry.Abort(err)
+		}
+
+		c.disconnect(kb, err)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, s := range states {
+		c.known[s.ID] = s
+	}
+	return nil
+}
+
+// connect opens the keyboard if necessary. It returns the keyboard and the
+// states to send: states itself if the keyboard was already open, or states
+// together with all known states after (re)connecting.
+func (c *Conn) connect(states []State) (*Keyboard, []State, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.kb != nil {
+		return c.kb, states, nil
+	}
+
+	kb, err := c.open()
+	if err != nil {
+		return nil, nil, err
+	}
+	c.kb = &kb
+	c.notify(Connected, nil)
+
+	return c.kb, c.knownStates(states), nil
+}
+
+// State returns the connection state.
+func (c *Conn) State() ConnState {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.kb == nil {
+		return Disconnected
+	}
+	return Connected
+}
+
+// LastCommitted returns the state of a key as last set successfully via c,
+// i.e. the state applied again after reconnecting. It returns false if the
+// key has not been set.
+func (c *Conn) LastCommitted(id Key) (State, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	s, ok := c.known[id]
+	return s, ok
+}
+
+// Close closes the connection to the keyboard, if any.
+func (c *Conn) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.kb == nil {
+		return nil
+	}
+
+	err := c.kb.Close()
+	c.kb = nil
+	c.notify(Disconnected, nil)
+	return err
+}
+
+// disconnect closes kb after err occurred, unless it has been closed or
+// replaced in the meantime.
+func (c *Conn) disconnect(kb *Keyboard, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.kb != kb {
+		return
+	}
+	c.kb.Close()
+	c.kb = nil
+	c.notify(Disconnected, err)
+}
+
+func (c *Conn) notify(s ConnState, err error) {
+	if c.onChange != nil {
+		c.onChange(s, err)
+	}
+}
+
+// knownStates returns the known states, updated with states, ordered by key.
+func (c *Conn) knownStates(states []State) []State {
+	merged := make(map[Key]State, len(c.known)+len(states))
+	for id, s := range c.known {
+		merged[id] = s
+	}
+	for _, s := range states {
+		merged[s.ID] = s
+	}
+
+	ret := make([]State, 0, len(merged))
+	for _, s := range merged {
+		ret = append(ret, s)
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].ID < ret[j].ID })
+	return ret
+}
@@ -0,0 +1,193 @@
+package dkb4q
+
+import (
+	"context"
+	"errors"
+	"image/color"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/octo/das/dkb4q/fake"
+)
+
+func TestConn(t *testing.T) {
+	var (
+		ctx        = context.Background()
+		hid0, hid1 fake.HID
+
+		esc = State{ID: KeyEsc, IdleEffect: SetColor, IdleColor: color.NRGBA{R: 0xFF}}
+		f1  = State{ID: KeyF1, IdleEffect: SetColor, IdleColor: color.NRGBA{B: 0xFF}}
+	)
+
+	// The first device is opened right away. After it has been
+	// disconnected, opening fails once before the second device is found.
+	opens := []func() (Keyboard, error){
+		func() (Keyboard, error) { return New(&hid0), nil },
+		func() (Keyboard, error) { return Keyboard{}, ErrNotFound },
+		func() (Keyboard, error) { return New(&hid1), nil },
+	}
+	open := func() (Keyboard, error) {
+		if len(opens) == 0 {
+			t.Fatal("unexpected call to open")
+		}
+		var f func() (Keyboard, error)
+		f, opens = opens[0], opens[1:]
+		return f()
+	}
+
+	type change struct {
+		State        ConnState
+		Disconnected bool
+	}
+	var changes []change
+	onChange := func(s ConnState, err error) {
+		changes = append(changes, change{
+			State:        s,
+			Disconnected: errors.Is(err, fake.ErrDisconnected),
+		})
+	}
+
+	c := NewConn(open, OnStateChange(onChange))
+	defer c.Close()
+
+	if got, want := c.State(), Disconnected; got != want {
+		t.Errorf("State() = %v, want %v", got, want)
+	}
+
+	expectSetState(&hid0, esc)
+	if err := c.SetState(ctx, esc); err != nil {
+		t.Fatalf("SetState(%v) = %v", esc.ID, err)
+	}
+
+	hid0.Disconnect()
+
+	// After reconnecting, the state of all known keys is applied.
+	expectSetState(&hid1, esc, f1)
+	if err := c.SetState(ctx, f1); err != nil {
+		t.Fatalf("SetState(%v) = %v", f1.ID, err)
+	}
+
+	if got, want := c.State(), Connected; got != want {
+		t.Errorf("State() = %v, want %v", got, want)
+	}
+
+	want := []change{
+		{State: Connected},
+		{State: Disconnected, Disconnected: true},
+		{State: Connected},
+	}
+	if diff := cmp.Diff(want, changes); diff != "" {
+		t.Errorf("state changes differ (+got/-want):\n%s", diff)
+	}
+
+	if len(hid1.WantSetReport) != 0 || len(hid1.WantGetReport) != 0 {
+		t.Errorf("not all expected reports were sent: %d SetReport and %d GetReport calls left",
+			len(hid1.WantSetReport), len(hid1.WantGetReport))
+	}
+}
+
+func TestConn_ResponseError(t *testing.T) {
+	var (
+		ctx = context.Background()
+		hid fake.HID
+		esc = State{ID: KeyEsc, IdleEffect: SetColor, IdleColor: color.NRGBA{R: 0xFF}}
+	)
+
+	expectSetState(&hid, esc)
+	hid.WantGetReport[0] = fake.Report{ID: 1, Data: []byte{0xED, 0x03, 0x78, 0x01, 0x97, 0, 0, 0}}
+
+	opened := 0
+	c := NewConn(func() (Keyboard, error) {
+		opened++
+		return New(&hid), nil
+	})
+	defer c.Close()
+
+	err := c.SetState(ctx, esc)
+	var resErr *ResponseError
+	if !errors.As(err, &resErr) {
+		t.Errorf("SetState() = %v, want *ResponseError", err)
+	}
+	if opened != 1 {
+		t.Errorf("keyboard opened %d times, want 1", opened)
+	}
+}
+
+func TestConn_RejectedStatesNotReplayed(t *testing.T) {
+	var (
+		ctx        = context.Background()
+		hid0, hid1 fake.HID
+
+		esc = State{ID: KeyEsc, IdleEffect: SetColor, IdleColor: color.NRGBA{R: 0xFF}}
+		f1  = State{ID: KeyF1, IdleEffect: SetColor, IdleColor: color.NRGBA{G: 0xFF}}
+		f2  = State{ID: KeyF2, IdleEffect: SetColor, IdleColor: color.NRGBA{B: 0xFF}}
+	)
+
+	devs := []*fake.HID{&hid0, &hid1}
+	c := NewConn(func() (Keyboard, error) {
+		var hid *fake.HID
+		hid, devs = devs[0], devs[1:]
+		return New(hid), nil
+	})
+	defer c.Close()
+
+	expectSetState(&hid0, esc)
+	hid0.WantGetReport[0] = fake.Report{ID: 1, Data: []byte{0xED, 0x03, 0x78, 0x01, 0x97, 0, 0, 0}}
+	if err := c.SetState(ctx, esc); err == nil {
+		t.Fatalf("SetState(%v) = %v, want error", esc.ID, err)
+	}
+	// SetState stops at the first error; drop the remaining reports.
+	hid0.WantSetReport, hid0.WantGetReport = nil, nil
+
+	expectSetState(&hid0, f1)
+	if err := c.SetState(ctx, f1); err != nil {
+		t.Fatalf("SetState(%v) = %v", f1.ID, err)
+	}
+
+	hid0.Disconnect()
+
+	// Esc was rejected by the keyboard and must not be sent again.
+	expectSetState(&hid1, f1, f2)
+	if err := c.SetState(ctx, f2); err != nil {
+		t.Fatalf("SetState(%v) = %v", f2.ID, err)
+	}
+
+	if len(hid1.WantSetReport) != 0 || len(hid1.WantGetReport) != 0 {
+		t.Errorf("not all expected reports were sent: %d SetReport and %d GetReport calls left",
+			len(hid1.WantSetReport), len(hid1.WantGetReport))
+	}
+}
+
+func TestConn_StateDuringReconnect(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	opened := make(chan struct{}, 1)
+	c := NewConn(func() (Keyboard, error) {
+		select {
+		case opened <- struct{}{}:
+		default:
+		}
+		return Keyboard{}, ErrNotFound
+	})
+
+	done := make(chan error)
+	go func() {
+		done <- c.SetState(ctx, State{ID: KeyEsc})
+	}()
+	<-opened
+
+	// SetState keeps retrying to open the keyboard. State and Close must
+	// not wait for it.
+	if got, want := c.State(), Disconnected; got != want {
+		t.Errorf("State() = %v, want %v", got, want)
+	}
+	if err := c.Close(); err != nil {
+		t.Errorf("Close() = %v", err)
+	}
+
+	cancel()
+	if err := <-done; err == nil {
+		t.Error("SetState() = nil, want error")
+	}
+}
@@ -0,0 +1,57 @@
+package dkb4q
+
+import (
+	"errors"
+
+	"github.com/octo/das/internal/usb"
+)
+
+// ledInterface is the USB interface used to control the LEDs.
+const ledInterface = 1
+
+// DeviceInfo describes a "Das Keyboard" USB device.
+//
+// The hid package does not provide the bus path or the serial number of a
+// device, so devices are identified by their position in the enumeration
+// order (Index) instead.
+type DeviceInfo = usb.DeviceInfo
+
+// OpenError is returned by OpenWith if no device could be opened. It lists
+// the candidate devices and why they were skipped.
+type OpenError = usb.OpenError
+
+// ErrNotFound is returned by Open and OpenWith if no matching device was
+// found.
+var ErrNotFound = usb.ErrNotFound
+
+var errNotSelected = errors.New("not selected")
+
+// List returns all devices that can be opened with OpenWith.
+func List() []DeviceInfo {
+	var ret []DeviceInfo
+	for _, info := range usb.List() {
+		if info.Interface != ledInterface {
+			continue
+		}
+		ret = append(ret, info)
+	}
+	return ret
+}
+
+// Selector selects the device opened by OpenWith. It returns true if the
+// device should be used.
+type Selector func(DeviceInfo) bool
+
+// ByProduct selects devices with the given USB product ID.
+func ByProduct(productID uint16) Selector {
+	return func(info DeviceInfo) bool {
+		return info.Product == productID
+	}
+}
+
+// ByIndex selects the device with the given index, as returned by List.
+func ByIndex(index int) Selector {
+	return func(info DeviceInfo) bool {
+		return info.Index == index
+	}
+}
@@ -0,0 +1,265 @@
+package dkb4q
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/octo/das/internal/usb"
+)
+
+// EventType is the type of an Event.
+type EventType int
+
+const (
+	// KeyPressed is sent when a key is pressed.
+	KeyPressed EventType = iota + 1
+	// KeyReleased is sent when a key is released.
+	KeyReleased
+	// KnobRotate is sent when the volume knob is turned.
+	KnobRotate
+	// QButton is sent when the Q button is pressed. The button's HID usage
+	// has not been confirmed with the hardware yet.
+	QButton
+	// EventError is the last event sent before the channel is closed
+	// because reading from the device failed.
+	EventError
+)
+
+func (t EventType) String() string {
+	switch t {
+	case KeyPressed:
+		return "KeyPressed"
+	case KeyReleased:
+		return "KeyReleased"
+	case KnobRotate:
+		return "KnobRotate"
+	case QButton:
+		return "QButton"
+	case EventError:
+		return "EventError"
+	default:
+		return fmt.Sprintf("EventType(%d)", int(t))
+	}
+}
+
+// Event is a user interaction with the keyboard.
+type Event struct {
+	Type EventType
+	// Key is the key pressed or released, for KeyPressed and KeyReleased.
+	Key Key
+	// Delta is +1 when the knob is turned clockwise (volume up) and -1
+	// when turned counter-clockwise, for KnobRotate.
+	Delta int
+	// Err is the error that ended the event stream, for EventError.
+	Err error
+}
+
+// InputDevice reads input reports from the keyboard. It is implemented by
+// hid.Device and fake.HID.
+type InputDevice interface {
+	Read(size int, timeout time.Duration) ([]byte, error)
+	Close()
+}
+
+// Input sets the device that Keyboard.Events reads input reports from.
+// Keyboard.Close closes dev.
+func Input(dev InputDevice) Option {
+	return func(kb *Keyboard) {
+		kb.input = dev
+	}
+}
+
+// inputInterface is the USB interface sending key presses.
+const inputInterface = 0
+
+// OpenInput opens the input interface of a keyboard, for use with the Input
+// option. A nil Selector accepts all devices.
+//
+// Note that the operating system usually has a driver attached to this
+// interface, which may have to be detached first. While this package reads
+// the input interface, key presses are not delivered to the operating system.
+func OpenInput(sel Selector) (InputDevice, error) {
+	dev, _, err := usb.Open(func(info DeviceInfo) error {
+		if info.Interface != inputInterface {
+			return fmt.Errorf("interface %d, want %d", info.Interface, inputInterface)
+		}
+		if sel != nil && !sel(info) {
+			return errNotSelected
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return dev, nil
+}
+
+// readTimeout is the timeout for reading one input report. It determines how
+// quickly Events notices that its context has been cancelled.
+const readTimeout = 100 * time.Millisecond
+
+// Events reads input reports and returns key presses, knob rotation and Q
+// button events. The returned channel is closed when ctx is cancelled. If
+// reading from the device fails, an EventError event is sent before closing
+// the channel. The input device must be set with the Input option.
+func (kb *Keyboard) Events(ctx context.Context) <-chan Event {
+	ch := make(chan Event)
+
+	var input InputDevice
+	if err := kb.lock(); err == nil {
+		input = kb.input
+		kb.mu.Unlock()
+	}
+
+	go func() {
+		defer close(ch)
+
+		send := func(ev Event) bool {
+			select {
+			case ch <- ev:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		if input == nil {
+			send(Event{Type: EventError, Err: fmt.Errorf("no input device; use the Input option")})
+			return
+		}
+
+		var dec eventDecoder
+		for ctx.Err() == nil {
+			data, err := input.Read(-1, readTimeout)
+			if isTimeout(err) {
+				continue
+			}
+			if err != nil {
+				send(Event{Type: EventError, Err: err})
+				return
+			}
+
+			for _, ev := range dec.decode(data) {
+				if !send(ev) {
+					return
+				}
+			}
+		}
+	}()
+
+	return ch
+}
+
+func isTimeout(err error) bool {
+	t, ok := err.(interface{ Timeout() bool })
+	return ok && t.Timeout()
+}
+
+const (
+	// consumerReportID is the report ID of consumer control reports, e.g.
+	// the volume knob.
+	consumerReportID = 0x02
+
+	usageVolumeUp   = 0x00E9
+	usageVolumeDown = 0x00EA
+	// The Q button's usage is a guess: unlike the volume knob's usages,
+	// it has not been confirmed with a USB trace.
+	usageQButton = 0x0221
+)
+
+// eventDecoder decodes input reports into events. It keeps the previous
+// keyboard report to determine which keys were pressed and released.
+type eventDecoder struct {
+	pressed map[Key]bool
+}
+
+// decode decodes one input report. Two formats are supported:
+//
+//   - Boot protocol keyboard reports: 8 bytes, holding a modifier bitmask, a
+//     reserved byte, and up to six usage IDs of pressed keys.
+//   - Consumer control reports: the report ID 0x02 followed by a 16 bit,
+//     little endian usage ID, or zero when released.
+func (d *eventDecoder) decode(data []byte) []Event {
+	switch {
+	case len(data) == 3 && data[0] == consumerReportID:
+		return d.decodeConsumer(uint16(data[1]) | uint16(data[2])<<8)
+	case len(data) == 8:
+		return d.decodeKeyboard(data)
+	default:
+		return nil
+	}
+}
+
+func (d *eventDecoder) decodeConsumer(usage uint16) []Event {
+	switch usage {
+	case usageVolumeUp:
+		return []Event{{Type: KnobRotate, Delta: 1}}
+	case usageVolumeDown:
+		return []Event{{Type: KnobRotate, Delta: -1}}
+	case usageQButton:
+		return []Event{{Type: QButton}}
+	default:
+		return nil
+	}
+}
+
+func (d *eventDecoder) decodeKeyboard(data []byte) []Event {
+	pressed := make(map[Key]bool)
+	for i, k := range modifierKeys {
+		if data[0]&(1<<uint(i)) != 0 {
+			pressed[k] = true
+		}
+	}
+	for _, usage := range data[2:] {
+		if k, ok := usageKeys[usage]; ok {
+			pressed[k] = true
+		}
+	}
+
+	var events []Event
+	for _, k := range Keys() {
+		switch {
+		case pressed[k] && !d.pressed[k]:
+			events = append(events, Event{Type: KeyPressed, Key: k})
+		case !pressed[k] && d.pressed[k]:
+			events = append(events, Event{Type: KeyReleased, Key: k})
+		}
+	}
+
+	d.pressed = pressed
+	return events
+}
+
+// modifierKeys maps the bits of the modifier byte to keys.
+var modifierKeys = [8]Key{
+	KeyLeftCtrl, KeyLeftShift, KeyLeftAlt, KeyLeftMeta,
+	KeyRightCtrl, KeyRightShift, KeyRightAlt, KeyRightMeta,
+}
+
+// usageKeys maps USB HID usage IDs (keyboard page) to keys.
+var usageKeys = map[byte]Key{
+	0x04: KeyA, 0x05: KeyB, 0x06: KeyC, 0x07: KeyD, 0x08: KeyE, 0x09: KeyF,
+	0x0A: KeyG, 0x0B: KeyH, 0x0C: KeyI, 0x0D: KeyJ, 0x0E: KeyK, 0x0F: KeyL,
+	0x10: KeyM, 0x11: KeyN, 0x12: KeyO, 0x13: KeyP, 0x14: KeyQ, 0x15: KeyR,
+	0x16: KeyS, 0x17: KeyT, 0x18: KeyU, 0x19: KeyV, 0x1A: KeyW, 0x1B: KeyX,
+	0x1C: KeyY, 0x1D: KeyZ,
+	0x1E: Key1, 0x1F: Key2, 0x20: Key3, 0x21: Key4, 0x22: Key5,
+	0x23: Key6, 0x24: Key7, 0x25: Key8, 0x26: Key9, 0x27: Key0,
+	0x28: KeyEnter, 0x29: KeyEsc, 0x2A: KeyBackspace, 0x2B: KeyTab, 0x2C: KeySpace,
+	0x2D: KeyMinus, 0x2E: KeyEqual, 0x2F: KeyLeftBracket, 0x30: KeyRightBracket,
+	0x31: KeyBackslash, 0x32: KeyNonUSHash, 0x33: KeySemicolon, 0x34: KeyQuote,
+	0x35: KeyBackquote, 0x36: KeyComma, 0x37: KeyPeriod, 0x38: KeySlash,
+	0x39: KeyCapsLock,
+	0x3A: KeyF1, 0x3B: KeyF2, 0x3C: KeyF3, 0x3D: KeyF4, 0x3E: KeyF5, 0x3F: KeyF6,
+	0x40: KeyF7, 0x41: KeyF8, 0x42: KeyF9, 0x43: KeyF10, 0x44: KeyF11, 0x45: KeyF12,
+	0x46: KeyPrintScreen, 0x47: KeyScrollLock, 0x48: KeyPause,
+	0x49: KeyInsert, 0x4A: KeyHome, 0x4B: KeyPageUp,
+	0x4C: KeyDelete, 0x4D: KeyEnd, 0x4E: KeyPageDown,
+	0x4F: KeyRight, 0x50: KeyLeft, 0x51: KeyDown, 0x52: KeyUp,
+	0x53: KeyNumLock, 0x54: KeyKPDivide, 0x55: KeyKPMultiply, 0x56: KeyKPMinus,
+	0x57: KeyKPPlus, 0x58: KeyKPEnter,
+	0x59: KeyKP1, 0x5A: KeyKP2, 0x5B: KeyKP3, 0x5C: KeyKP4, 0x5D: KeyKP5,
+	0x5E: KeyKP6, 0x5F: KeyKP7, 0x60: KeyKP8, 0x61: KeyKP9, 0x62: KeyKP0,
+	0x63: KeyKPDecimal, 0x64: KeyNonUSBackslash, 0x65: KeyMenu,
+}
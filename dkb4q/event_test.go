@@ -0,0 +1,85 @@
+package dkb4q
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/octo/das/dkb4q/fake"
+)
+
+func TestKeyboard_Events(t *testing.T) {
+	hid := fake.HID{
+		Input: [][]byte{
+			{0x00, 0x00, 0x3A, 0x00, 0x00, 0x00, 0x00, 0x00}, // F1
+			{0x02, 0x00, 0x3A, 0x04, 0x00, 0x00, 0x00, 0x00}, // F1, LeftShift, A
+			{0x02, 0x00, 0x04, 0x00, 0x00, 0x00, 0x00, 0x00}, // LeftShift, A
+			{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}, // none
+			{0x02, 0xE9, 0x00}, // volume up
+			{0x02, 0x00, 0x00}, // release
+			{0x02, 0xEA, 0x00}, // volume down
+			{0x02, 0x21, 0x02}, // Q button
+			{0x01, 0x02, 0x03}, // unknown
+		},
+	}
+
+	kb := New(nil, Input(&hid))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	want := []Event{
+		{Type: KeyPressed, Key: KeyF1},
+		// Events of one report are ordered by key ID.
+		{Type: KeyPressed, Key: KeyLeftShift},
+		{Type: KeyPressed, Key: KeyA},
+		{Type: KeyReleased, Key: KeyF1},
+		{Type: KeyReleased, Key: KeyLeftShift},
+		{Type: KeyReleased, Key: KeyA},
+		{Type: KnobRotate, Delta: 1},
+		{Type: KnobRotate, Delta: -1},
+		{Type: QButton},
+	}
+
+	ch := kb.Events(ctx)
+	var got []Event
+	for ev := range ch {
+		got = append(got, ev)
+		if len(got) == len(want) {
+			cancel()
+		}
+	}
+
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Events() differs (+got/-want):\n%s", diff)
+	}
+}
+
+func TestKeyboard_EventsError(t *testing.T) {
+	var hid fake.HID
+	hid.Disconnect()
+
+	kb := New(nil, Input(&hid))
+
+	var got []Event
+	for ev := range kb.Events(context.Background()) {
+		got = append(got, ev)
+	}
+
+	if len(got) != 1 || got[0].Type != EventError || got[0].Err != fake.ErrDisconnected {
+		t.Errorf("Events() = %+v, want a single EventError with %v", got, fake.ErrDisconnected)
+	}
+}
+
+func TestKeyboard_CloseInput(t *testing.T) {
+	var dev, input fake.HID
+
+	kb := New(&dev, Input(&input))
+	if err := kb.Close(); err != nil {
+		t.Fatalf("Close() = %v", err)
+	}
+
+	if _, err := input.Read(-1, 0); err == nil {
+		t.Error("input device is still open after Keyboard.Close")
+	}
+}
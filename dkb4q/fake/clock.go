@@ -0,0 +1,75 @@
+package fake
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Clock is a fake implementation of dkb4q.Clock. Time only passes when
+// Advance is called.
+//
+// Clock is safe for concurrent use.
+type Clock struct {
+	mu     sync.Mutex
+	now    time.Duration
+	timers []*timer
+}
+
+type timer struct {
+	when    time.Duration
+	f       func()
+	stopped bool
+}
+
+// AfterFunc implements dkb4q.Clock. f is called by Advance, once the clock
+// has been advanced by d.
+func (c *Clock) AfterFunc(d time.Duration, f func()) func() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	t := &timer{
+		when: c.now + d,
+		f:    f,
+	}
+	c.timers = append(c.timers, t)
+
+	return func() bool {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+
+		if t.stopped {
+			return false
+		}
+		t.stopped = true
+		return true
+	}
+}
+
+// Advance advances the clock by d and calls all functions that became due,
+// in order. The functions are called synchronously, i.e. Advance returns after
+// they have returned.
+func (c *Clock) Advance(d time.Duration) {
+	c.mu.Lock()
+	c.now += d
+	now := c.now
+
+	var due, pending []*timer
+	for _, t := range c.timers {
+		switch {
+		case t.stopped:
+		case t.when <= now:
+			t.stopped = true
+			due = append(due, t)
+		default:
+			pending = append(pending, t)
+		}
+	}
+	c.timers = pending
+	c.mu.Unlock()
+
+	sort.SliceStable(due, func(i, j int) bool { return due[i].when < due[j].when })
+	for _, t := range due {
+		t.f()
+	}
+}
@@ -0,0 +1,133 @@
+package fake
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/octo/retry"
+)
+
+// Device is the interface implemented by HID and Simulator. It matches
+// dkb4q.Device.
+type Device interface {
+	Close()
+	SetReport(int, []byte) error
+	GetReport(int) ([]byte, error)
+}
+
+var (
+	// ErrTransient is returned by Faulty for the first TransientErrors
+	// calls. It is not marked as permanent, i.e. retry.Do retries it.
+	ErrTransient = errors.New("transient error")
+	// ErrPermanent is returned by Faulty for all calls after the first
+	// FailAfter calls. It is wrapped with retry.Abort.
+	ErrPermanent = errors.New("permanent error")
+)
+
+// Faulty wraps a Device and injects faults into the communication. The zero
+// values of all fields disable the respective fault.
+//
+// Faulty is safe for concurrent use if the wrapped Device is.
+type Faulty struct {
+	Device Device
+
+	// Delay is added to every SetReport and GetReport call.
+	Delay time.Duration
+	// TransientErrors is the number of calls, counting both SetReport and
+	// GetReport, that fail with ErrTransient. Calls failing this way are
+	// not passed on to Device.
+	TransientErrors int
+	// FailAfter, if positive, is the number of calls that succeed. All
+	// later calls fail with ErrPermanent, e.g. because the device has been
+	// unplugged.
+	FailAfter int
+	// DropResponses is the number of non-zero reports returned by
+	// GetReport that are replaced with zeros, i.e. responses which never
+	// arrive.
+	DropResponses int
+	// Corrupt, if set, is called with every report returned by GetReport
+	// and returns the report to return instead. This can be used to flip
+	// bytes, truncate reports or add stray bytes. data may be modified.
+	Corrupt func(data []byte) []byte
+
+	mu    sync.Mutex
+	calls int
+}
+
+// Close implements dkb4q.Device.
+func (f *Faulty) Close() {
+	f.Device.Close()
+}
+
+// SetReport implements dkb4q.Device.
+func (f *Faulty) SetReport(id int, data []byte) error {
+	if err := f.call(); err != nil {
+		return err
+	}
+	return f.Device.SetReport(id, data)
+}
+
+// GetReport implements dkb4q.Device.
+func (f *Faulty) GetReport(id int) ([]byte, error) {
+	if err := f.call(); err != nil {
+		return nil, err
+	}
+
+	data, err := f.Device.GetReport(id)
+	if err != nil {
+		return nil, err
+	}
+	data = append([]byte{}, data...)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.DropResponses > 0 && !isZero(data) {
+		f.DropResponses--
+		for i := range data {
+			data[i] = 0
+		}
+	}
+
+	if f.Corrupt != nil {
+		data = f.Corrupt(data)
+	}
+
+	return data, nil
+}
+
+func (f *Faulty) call() error {
+	f.mu.Lock()
+	f.calls++
+	var (
+		delay     = f.Delay
+		permanent = f.FailAfter > 0 && f.calls > f.FailAfter
+		transient = f.TransientErrors > 0
+	)
+	if transient {
+		f.TransientErrors--
+	}
+	f.mu.Unlock()
+
+	if delay > 0 {
+		time.Sleep(delay)
+	}
+
+	if permanent {
+		return retry.Abort(ErrPermanent)
+	}
+	if transient {
+		return ErrTransient
+	}
+	return nil
+}
+
+func isZero(data []byte) bool {
+	for _, b := range data {
+		if b != 0x00 {
+			return false
+		}
+	}
+	return true
+}
@@ -1,38 +1,59 @@
+// Package fake provides a fake HID device for testing code that uses the
+// dkb4q package without a keyboard attached.
 package fake
 
 import (
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/octo/retry"
 )
 
+// Report is a HID report with its report ID.
 type Report struct {
 	ID   int
 	Data []byte
 }
 
-// HID is a fake implementation of the HID object used by dkb4q.Keyboard.
+// HID is a fake implementation of dkb4q.Device. It expects exactly the
+// reports in WantSetReport and returns the reports in WantGetReport, in order.
+// Use dkb4q.New to create a Keyboard using a HID.
 type HID struct {
 	WantSetReport []Report
 	WantGetReport []Report
-	closed        bool
+	// Input holds the input reports returned by Read.
+	Input        [][]byte
+	closed       bool
+	disconnected bool
 }
 
 func (d *HID) Close() {
 	d.closed = true
 }
 
+// ErrDisconnected is returned by all calls after Disconnect has been called.
+var ErrDisconnected = errors.New("device disconnected")
+
 var (
 	errClosed     = retry.Abort(errors.New("device is closed"))
 	errUnexpected = retry.Abort(errors.New("unexpected call"))
 )
 
+// Disconnect simulates unplugging the device. All subsequent calls to
+// SetReport and GetReport fail with ErrDisconnected.
+func (d *HID) Disconnect() {
+	d.disconnected = true
+}
+
 func (d *HID) SetReport(id int, data []byte) error {
 	if d.closed {
 		return errClosed
 	}
+	if d.disconnected {
+		return ErrDisconnected
+	}
 	if len(d.WantSetReport) == 0 {
 		return errUnexpected
 	}
@@ -56,6 +77,9 @@ func (d *HID) GetReport(id int) ([]byte, error) {
 	if d.closed {
 		return nil, errClosed
 	}
+	if d.disconnected {
+		return nil, ErrDisconnected
+	}
 	if len(d.WantGetReport) == 0 {
 		return nil, errUnexpected
 	}
@@ -69,3 +93,32 @@ func (d *HID) GetReport(id int) ([]byte, error) {
 
 	return res.Data, nil
 }
+
+// Read implements dkb4q.InputDevice. It returns the reports in Input, in
+// order. Once all reports have been returned, Read waits for timeout and
+// returns a timeout error.
+func (d *HID) Read(size int, timeout time.Duration) ([]byte, error) {
+	if d.closed {
+		return nil, errClosed
+	}
+	if d.disconnected {
+		return nil, ErrDisconnected
+	}
+	if len(d.Input) == 0 {
+		time.Sleep(timeout)
+		return nil, errTimeout{}
+	}
+
+	var data []byte
+	data, d.Input = d.Input[0], d.Input[1:]
+
+	if size >= 0 && len(data) > size {
+		data = data[:size]
+	}
+	return data, nil
+}
+
+type errTimeout struct{}
+
+func (errTimeout) Error() string { return "timeout" }
+func (errTimeout) Timeout() bool { return true }
@@ -0,0 +1,213 @@
+package fake
+
+import (
+	"fmt"
+	"image/color"
+	"sync"
+
+	"github.com/octo/retry"
+)
+
+// KeyState is the state of one key, as tracked by Simulator. Since the
+// keyboard has no notion of transparency, the alpha channel of colors is
+// always 0xFF.
+type KeyState struct {
+	IdleEffect   uint8
+	IdleColor    color.NRGBA
+	ActiveEffect uint8
+	ActiveColor  color.NRGBA
+	// ActiveArgs are the effect specific arguments of the active effect.
+	ActiveArgs [3]byte
+}
+
+// Simulator simulates a "Das Keyboard 4Q". Unlike HID, which expects a fixed
+// script of reports, Simulator decodes the commands sent to it, keeps track
+// of the staged and committed state of each key, and responds like the
+// keyboard does. This allows tests to check the resulting state of the
+// keyboard, e.g. "F1 is red", instead of matching bytes.
+//
+// Simulator is safe for concurrent use.
+type Simulator struct {
+	mu        sync.Mutex
+	in        []byte // partial message
+	out       []byte // pending responses
+	staged    map[uint8]KeyState
+	committed map[uint8]KeyState
+	commits   int
+	closed    bool
+}
+
+// NewSimulator returns a new Simulator with all keys turned off.
+func NewSimulator() *Simulator {
+	return &Simulator{
+		staged:    make(map[uint8]KeyState),
+		committed: make(map[uint8]KeyState),
+	}
+}
+
+// Committed returns the committed state of a key, i.e. the state shown by the
+// keyboard. The second return value is false if the key has never been set.
+func (s *Simulator) Committed(id uint8) (KeyState, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ks, ok := s.committed[id]
+	return ks, ok
+}
+
+// Staged returns the state of a key that has been staged but not yet
+// committed.
+func (s *Simulator) Staged(id uint8) (KeyState, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ks, ok := s.staged[id]
+	return ks, ok
+}
+
+// Commits returns the number of commit commands received.
+func (s *Simulator) Commits() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.commits
+}
+
+// Close implements dkb4q.Device.
+func (s *Simulator) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.closed = true
+}
+
+const (
+	commandReport  = 0xEA
+	responseReport = 0xED
+	ledCommand     = 0x78
+
+	statusOK    = 0x00
+	statusError = 0x01
+)
+
+// SetReport implements dkb4q.Device. It expects 8 byte reports: the report
+// prefix 0x01 followed by seven bytes of a message.
+func (s *Simulator) SetReport(id int, data []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return errClosed
+	}
+	if id != 1 {
+		return retry.Abort(fmt.Errorf("report ID = %d, want 1", id))
+	}
+	if len(data) != 8 || data[0] != 0x01 {
+		return retry.Abort(fmt.Errorf("invalid report %#v", data))
+	}
+
+	s.in = append(s.in, data[1:]...)
+
+	// The message is complete once 2+length bytes, padded to a multiple of
+	// seven, have been received.
+	msgLen := 2 + int(s.in[1])
+	if rem := msgLen % 7; rem != 0 {
+		msgLen += 7 - rem
+	}
+	if len(s.in) < msgLen {
+		return nil
+	}
+
+	msg := s.in[:2+int(s.in[1])]
+	s.in = nil
+
+	if err := s.handle(msg); err != nil {
+		return retry.Abort(err)
+	}
+	return nil
+}
+
+// GetReport implements dkb4q.Device. It returns an 8 byte report holding the
+// next pending response, or all zeros if no response is pending.
+func (s *Simulator) GetReport(id int) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return nil, errClosed
+	}
+	if id != 1 {
+		return nil, retry.Abort(fmt.Errorf("report ID = %d, want 1", id))
+	}
+
+	data := make([]byte, 8)
+	n := copy(data, s.out)
+	s.out = s.out[n:]
+
+	return data, nil
+}
+
+// handle processes one message: report type, length, payload and parity.
+func (s *Simulator) handle(msg []byte) error {
+	if msg[0] != commandReport {
+		return fmt.Errorf("unexpected report type %#x", msg[0])
+	}
+	if len(msg) < 3 {
+		return fmt.Errorf("message %#v too short", msg)
+	}
+	if got, want := msg[len(msg)-1], xorAll(msg[:len(msg)-1]); got != want {
+		s.respond(ledCommand, statusError)
+		return nil
+	}
+
+	payload := msg[2 : len(msg)-1]
+	if len(payload) < 2 || payload[0] != ledCommand {
+		s.respond(ledCommand, statusError)
+		return nil
+	}
+
+	switch cmd := payload[1]; {
+	case cmd == 0x03 && len(payload) == 10: // select key
+		id := payload[2]
+		s.staged[id] = s.staged[id]
+		s.respond(ledCommand, statusOK)
+	case cmd == 0x08 && len(payload) == 7: // idle state; not acknowledged
+		id := payload[2]
+		ks := s.staged[id]
+		ks.IdleEffect = payload[3]
+		ks.IdleColor = color.NRGBA{R: payload[4], G: payload[5], B: payload[6], A: 0xFF}
+		s.staged[id] = ks
+	case cmd == 0x04 && len(payload) == 10: // active state
+		id := payload[2]
+		ks := s.staged[id]
+		ks.ActiveEffect = payload[3]
+		ks.ActiveColor = color.NRGBA{R: payload[4], G: payload[5], B: payload[6], A: 0xFF}
+		copy(ks.ActiveArgs[:], payload[7:10])
+		s.staged[id] = ks
+		s.respond(ledCommand, statusOK)
+	case cmd == 0x0A && len(payload) == 2: // commit
+		for id, ks := range s.staged {
+			s.committed[id] = ks
+		}
+		s.staged = make(map[uint8]KeyState)
+		s.commits++
+		s.respond(ledCommand, statusOK)
+	default:
+		s.respond(ledCommand, statusError)
+	}
+
+	return nil
+}
+
+func (s *Simulator) respond(command, status byte) {
+	res := []byte{responseReport, 0x03, command, status}
+	s.out = append(s.out, append(res, xorAll(res))...)
+}
+
+func xorAll(data []byte) byte {
+	var ret byte
+	for _, d := range data {
+		ret ^= d
+	}
+	return ret
+}
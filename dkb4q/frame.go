@@ -0,0 +1,102 @@
+package dkb4q
+
+import (
+	"context"
+	"sort"
+)
+
+// Frame is a frame buffer on top of Keyboard.SetState. It remembers the state
+// last committed to the keyboard and only sends keys whose state changed.
+//
+// Frame assumes that it is the only one changing the keyboard's state. If
+// something else may have changed the keys, call Invalidate or Sync.
+//
+// Unlike Keyboard, a Frame is not safe for concurrent use.
+type Frame struct {
+	kb        StateSetter
+	committed map[Key]State
+}
+
+// StateSetter sets the state of keys. It is implemented by *Keyboard and
+// *Conn.
+type StateSetter interface {
+	SetState(ctx context.Context, states ...State) error
+}
+
+// NewFrame returns a new Frame writing to kb. Initially the state of the
+// keyboard is unknown, so the first Apply sends all keys.
+func NewFrame(kb StateSetter) *Frame {
+	return &Frame{
+		kb:        kb,
+		committed: make(map[Key]State),
+	}
+}
+
+// Apply sets the keyboard to the desired states. Keys whose state matches the
+// last committed state are skipped; all others are sent in a single commit.
+// Keys not included in states are left unchanged. If a key is included more
+// than once, the last state wins.
+//
+// If nothing changed, Apply does not communicate with the keyboard at all.
+func (f *Frame) Apply(ctx context.Context, states ...State) error {
+	desired := make(map[Key]State, len(states))
+	for _, s := range states {
+		desired[s.ID] = s
+	}
+
+	var changed []State
+	for id, s := range desired {
+		if old, ok := f.committed[id]; ok && old == s {
+			continue
+		}
+		changed = append(changed, s)
+	}
+
+	return f.send(ctx, changed)
+}
+
+// Sync sends the state of all keys known to the Frame, whether they changed
+// or not.
+func (f *Frame) Sync(ctx context.Context) error {
+	states := make([]State, 0, len(f.committed))
+	for _, s := range f.committed {
+		states = append(states, s)
+	}
+
+	return f.send(ctx, states)
+}
+
+// Invalidate forgets the committed state, so that the next Apply sends all
+// keys passed to it.
+func (f *Frame) Invalidate() {
+	f.committed = make(map[Key]State)
+}
+
+// State returns the state last committed for the key, if any.
+func (f *Frame) State(id Key) (State, bool) {
+	s, ok := f.committed[id]
+	return s, ok
+}
+
+func (f *Frame) send(ctx context.Context, states []State) error {
+	if len(states) == 0 {
+		return nil
+	}
+
+	// Send keys in a deterministic order.
+	sort.Slice(states, func(i, j int) bool { return states[i].ID < states[j].ID })
+
+	if err := f.kb.SetState(ctx, states...); err != nil {
+		// The keys may or may not have been updated. Forget their
+		// state so that they are sent again by the next Apply.
+		for _, s := range states {
+			delete(f.committed, s.ID)
+		}
+		return err
+	}
+
+	for _, s := range states {
+		f.committed[s.ID] = s
+	}
+	return nil
+}
@@ -0,0 +1,119 @@
+package dkb4q
+
+import (
+	"context"
+	"image/color"
+	"testing"
+
+	"github.com/octo/das/dkb4q/fake"
+)
+
+var ackReport = fake.Report{ID: 1, Data: []byte{0xED, 0x03, 0x78, 0x00, 0x96, 0, 0, 0}}
+
+// expectSetState scripts hid with the reports sent by SetState(states...).
+func expectSetState(hid *fake.HID, states ...State) {
+	expect := func(msg []byte) {
+		for i := 0; i < len(msg); i += 7 {
+			hid.WantSetReport = append(hid.WantSetReport, fake.Report{
+				ID:   1,
+				Data: append([]byte{0x01}, msg[i:i+7]...),
+			})
+		}
+	}
+
+	for _, s := range states {
+		expect(encodeReport(0xEA, []byte{0x78, 0x03, byte(s.ID), 0, 0, 0, 0, 0, 0, 0}))
+		hid.WantGetReport = append(hid.WantGetReport, ackReport)
+		expect(encodeReport(0xEA, []byte{0x78, 0x08, byte(s.ID), byte(s.IdleEffect),
+			s.IdleColor.R, s.IdleColor.G, s.IdleColor.B}))
+		expect(encodeReport(0xEA, []byte{0x78, 0x04, byte(s.ID), s.ActiveEffect.id,
+			s.ActiveColor.R, s.ActiveColor.G, s.ActiveColor.B,
+			s.ActiveEffect.arg0, s.ActiveEffect.arg1, s.ActiveEffect.arg2}))
+		hid.WantGetReport = append(hid.WantGetReport, ackReport)
+	}
+
+	expect(encodeReport(0xEA, []byte{0x78, 0x0A}))
+	hid.WantGetReport = append(hid.WantGetReport, ackReport)
+}
+
+func TestFrame(t *testing.T) {
+	var (
+		ctx = context.Background()
+		hid fake.HID
+
+		red  = State{ID: KeyF1, IdleEffect: SetColor, IdleColor: color.NRGBA{R: 0xFF}}
+		blue = State{ID: KeyF2, IdleEffect: SetColor, IdleColor: color.NRGBA{B: 0xFF}}
+	)
+
+	kb := New(&hid)
+	defer kb.Close()
+
+	f := NewFrame(&kb)
+
+	// Initially, all keys are sent.
+	expectSetState(&hid, red, blue)
+	if err := f.Apply(ctx, blue, red); err != nil {
+		t.Fatalf("Apply() = %v", err)
+	}
+
+	// Nothing changed: no communication with the keyboard.
+	if err := f.Apply(ctx, red, blue); err != nil {
+		t.Fatalf("Apply() = %v", err)
+	}
+
+	// Only the changed key is sent.
+	green := State{ID: KeyF2, IdleEffect: SetColor, IdleColor: color.NRGBA{G: 0xFF}}
+	expectSetState(&hid, green)
+	if err := f.Apply(ctx, red, green); err != nil {
+		t.Fatalf("Apply() = %v", err)
+	}
+
+	// Sync sends all known keys.
+	expectSetState(&hid, red, green)
+	if err := f.Sync(ctx); err != nil {
+		t.Fatalf("Sync() = %v", err)
+	}
+
+	// After Invalidate, Apply sends all keys again.
+	f.Invalidate()
+	expectSetState(&hid, red)
+	if err := f.Apply(ctx, red); err != nil {
+		t.Fatalf("Apply() = %v", err)
+	}
+
+	if len(hid.WantSetReport) != 0 || len(hid.WantGetReport) != 0 {
+		t.Errorf("not all expected reports were sent: %d SetReport and %d GetReport calls left",
+			len(hid.WantSetReport), len(hid.WantGetReport))
+	}
+}
+
+func TestFrame_Error(t *testing.T) {
+	var (
+		ctx = context.Background()
+		hid fake.HID
+
+		red = State{ID: KeyF1, IdleEffect: SetColor, IdleColor: color.NRGBA{R: 0xFF}}
+	)
+
+	kb := New(&hid)
+	defer kb.Close()
+
+	f := NewFrame(&kb)
+
+	// No reports scripted: SetState fails.
+	if err := f.Apply(ctx, red); err == nil {
+		t.Fatal("Apply() = nil, want error")
+	}
+	if _, ok := f.State(KeyF1); ok {
+		t.Errorf("State(%v) is known after a failed Apply", KeyF1)
+	}
+
+	// The failed key is sent again.
+	expectSetState(&hid, red)
+	if err := f.Apply(ctx, red); err != nil {
+		t.Fatalf("Apply() = %v", err)
+	}
+	if got, ok := f.State(KeyF1); !ok || got != red {
+		t.Errorf("State(%v) = %v, %v, want %v, true", KeyF1, got, ok, red)
+	}
+}
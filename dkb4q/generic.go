@@ -0,0 +1,121 @@
+package dkb4q
+
+import (
+	"context"
+	"fmt"
+	"image/color"
+	"sort"
+	"strings"
+)
+
+// The methods in this file implement the model-agnostic driver.Keyboard
+// interface. Keys are identified by name, see KeyByName, and effects by the
+// names returned by IdleEffect.String.
+
+var idleEffectNames = map[IdleEffect]string{
+	SetColor:   "set_color",
+	Breathe:    "breathe",
+	Blink:      "blink",
+	ColorCycle: "color_cycle",
+}
+
+func (e IdleEffect) String() string {
+	if name, ok := idleEffectNames[e]; ok {
+		return name
+	}
+	return fmt.Sprintf("IdleEffect(0x%02X)", uint8(e))
+}
+
+// ParseIdleEffect returns the idle effect with the given name, e.g.
+// "breathe". Names are case insensitive. Like KeyByName, the "IdleEffect(0x07)"
+// form returned by IdleEffect.String for unnamed effects is also accepted.
+func ParseIdleEffect(name string) (IdleEffect, error) {
+	for e, n := range idleEffectNames {
+		if strings.EqualFold(n, name) {
+			return e, nil
+		}
+	}
+	if e, ok := parseIdleEffectID(name); ok {
+		return e, nil
+	}
+	return 0, fmt.Errorf("unknown effect %q", name)
+}
+
+// Keys returns the names of all keys, ordered by key ID.
+func (kb *Keyboard) Keys() []string {
+	keys := Keys()
+	names := make([]string, 0, len(keys))
+	for _, k := range keys {
+		names = append(names, k.String())
+	}
+	return names
+}
+
+// SetColor stages a static color for keys. The change takes effect when
+// Commit is called.
+func (kb *Keyboard) SetColor(ctx context.Context, c color.NRGBA, keys ...string) error {
+	return kb.SetEffect(ctx, SetColor.String(), c, keys...)
+}
+
+// SetEffect stages an idle effect, e.g. "breathe", for keys. The keys' active
+// effects are left unchanged. The change takes effect when Commit is called.
+func (kb *Keyboard) SetEffect(ctx context.Context, effect string, c color.NRGBA, keys ...string) error {
+	e, err := ParseIdleEffect(effect)
+	if err != nil {
+		return err
+	}
+
+	ids := make([]Key, 0, len(keys))
+	for _, name := range keys {
+		id, err := KeyByName(name)
+		if err != nil {
+			return err
+		}
+		ids = append(ids, id)
+	}
+
+	if err := kb.lock(); err != nil {
+		return err
+	}
+	defer kb.mu.Unlock()
+
+	for _, id := range ids {
+		s, ok := kb.pending[id]
+		if !ok {
+			s, ok = kb.committed[id]
+		}
+		if !ok {
+			s = State{ID: id, ActiveEffect: None}
+		}
+		s.IdleEffect = e
+		s.IdleColor = c
+		kb.pending[id] = s
+	}
+	return nil
+}
+
+// Commit sends all states staged by SetColor and SetEffect to the keyboard.
+// If sending fails, the states remain staged.
+func (kb *Keyboard) Commit(ctx context.Context) error {
+	if err := kb.lock(); err != nil {
+		return err
+	}
+	defer kb.mu.Unlock()
+
+	if len(kb.pending) == 0 {
+		return nil
+	}
+
+	states := make([]State, 0, len(kb.pending))
+	for _, s := range kb.pending {
+		states = append(states, s)
+	}
+	sort.Slice(states, func(i, j int) bool { return states[i].ID < states[j].ID })
+
+	if err := kb.setStateLocked(ctx, states); err != nil {
+		return err
+	}
+
+	kb.pending = make(map[Key]State)
+	return nil
+}
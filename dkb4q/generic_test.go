@@ -0,0 +1,91 @@
+package dkb4q
+
+import (
+	"context"
+	"image/color"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/octo/das/dkb4q/fake"
+)
+
+func TestKeyboard_SetColorCommit(t *testing.T) {
+	var (
+		red  = color.NRGBA{R: 0xFF, A: 0xFF}
+		blue = color.NRGBA{B: 0xFF, A: 0xFF}
+	)
+
+	ctx := context.Background()
+	sim := fake.NewSimulator()
+	kb := New(sim)
+	defer kb.Close()
+
+	if err := kb.SetState(ctx, State{ID: KeyF2, IdleEffect: SetColor, ActiveEffect: BlinkActive(), ActiveColor: red}); err != nil {
+		t.Fatalf("Keyboard.SetState() = %v", err)
+	}
+
+	if err := kb.SetColor(ctx, red, "F1", "esc"); err != nil {
+		t.Fatalf("Keyboard.SetColor() = %v", err)
+	}
+	if err := kb.SetEffect(ctx, "breathe", blue, "F2"); err != nil {
+		t.Fatalf("Keyboard.SetEffect() = %v", err)
+	}
+	if got := sim.Commits(); got != 1 {
+		t.Errorf("Commits() = %d before Commit, want 1", got)
+	}
+
+	if err := kb.Commit(ctx); err != nil {
+		t.Fatalf("Keyboard.Commit() = %v", err)
+	}
+
+	want := map[Key]fake.KeyState{
+		KeyEsc: {IdleEffect: uint8(SetColor), IdleColor: red, ActiveColor: color.NRGBA{A: 0xFF}},
+		KeyF1:  {IdleEffect: uint8(SetColor), IdleColor: red, ActiveColor: color.NRGBA{A: 0xFF}},
+		// the active effect set with SetState is retained.
+		KeyF2: {IdleEffect: uint8(Breathe), IdleColor: blue, ActiveEffect: uint8(Blink), ActiveColor: red, ActiveArgs: [3]byte{0x01, 0xF4, 0x03}},
+	}
+	for id, want := range want {
+		got, ok := sim.Committed(uint8(id))
+		if !ok {
+			t.Errorf("key %v has not been committed", id)
+			continue
+		}
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Errorf("key %v differs (+got/-want):\n%s", id, diff)
+		}
+	}
+
+	if got := sim.Commits(); got != 2 {
+		t.Errorf("Commits() = %d, want 2", got)
+	}
+
+	// nothing is staged after a successful commit.
+	if err := kb.Commit(ctx); err != nil {
+		t.Fatalf("Keyboard.Commit() = %v", err)
+	}
+	if got := sim.Commits(); got != 2 {
+		t.Errorf("Commits() = %d after empty Commit, want 2", got)
+	}
+}
+
+func TestKeyboard_SetEffectErrors(t *testing.T) {
+	ctx := context.Background()
+	kb := New(fake.NewSimulator())
+	defer kb.Close()
+
+	if err := kb.SetEffect(ctx, "sparkle", color.NRGBA{}, "F1"); err == nil {
+		t.Error(`Keyboard.SetEffect("sparkle") succeeded, want error`)
+	}
+	if err := kb.SetColor(ctx, color.NRGBA{}, "NoSuchKey"); err == nil {
+		t.Error(`Keyboard.SetColor("NoSuchKey") succeeded, want error`)
+	}
+}
+
+func TestParseIdleEffect(t *testing.T) {
+	for _, e := range []IdleEffect{SetColor, Breathe, Blink, ColorCycle} {
+		got, err := ParseIdleEffect(e.String())
+		if err != nil || got != e {
+			t.Errorf("ParseIdleEffect(%q) = (%v, %v), want (%v, nil)", e.String(), got, err, e)
+		}
+	}
+}
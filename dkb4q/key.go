@@ -0,0 +1,235 @@
+package dkb4q
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Key identifies a key, or rather its LED, on the keyboard.
+//
+// LEDs are numbered column by column, starting in the bottom left corner of
+// the keyboard. Each column has six LEDs, from the bottom row (Ctrl, Alt,
+// Space) to the function key row (Esc, F1–F12), i.e. the ID of a key is
+// 6*column + row. Positions without a physical key, for example the gap
+// between Esc and F1, still have an ID but no name.
+//
+// The names follow the US (ANSI) layout. The two additional keys of the ISO
+// layout are called NonUSBackslash and NonUSHash, like in the USB HID usage
+// tables.
+type Key uint8
+
+// Keys of the main block. The comment on each line lists the column.
+const (
+	KeyLeftCtrl  Key = 0x00 // column 0
+	KeyLeftShift Key = 0x01
+	KeyCapsLock  Key = 0x02
+	KeyTab       Key = 0x03
+	KeyBackquote Key = 0x04
+	KeyEsc       Key = 0x05
+
+	KeyLeftMeta       Key = 0x06 // column 1
+	KeyNonUSBackslash Key = 0x07
+	KeyA              Key = 0x08
+	KeyQ              Key = 0x09
+	Key1              Key = 0x0A
+
+	KeyLeftAlt Key = 0x0C // column 2
+	KeyZ       Key = 0x0D
+	KeyS       Key = 0x0E
+	KeyW       Key = 0x0F
+	Key2       Key = 0x10
+	KeyF1      Key = 0x11
+
+	KeyX  Key = 0x13 // column 3
+	KeyD  Key = 0x14
+	KeyE  Key = 0x15
+	Key3  Key = 0x16
+	KeyF2 Key = 0x17
+
+	KeyC  Key = 0x19 // column 4
+	KeyF  Key = 0x1A
+	KeyR  Key = 0x1B
+	Key4  Key = 0x1C
+	KeyF3 Key = 0x1D
+
+	KeyV  Key = 0x1F // column 5
+	KeyG  Key = 0x20
+	KeyT  Key = 0x21
+	Key5  Key = 0x22
+	KeyF4 Key = 0x23
+
+	KeySpace Key = 0x24 // column 6
+	KeyB     Key = 0x25
+	KeyH     Key = 0x26
+	KeyY     Key = 0x27
+	Key6     Key = 0x28
+	KeyF5    Key = 0x29
+
+	KeyN  Key = 0x2B // column 7
+	KeyJ  Key = 0x2C
+	KeyU  Key = 0x2D
+	Key7  Key = 0x2E
+	KeyF6 Key = 0x2F
+
+	KeyM  Key = 0x31 // column 8
+	KeyK  Key = 0x32
+	KeyI  Key = 0x33
+	Key8  Key = 0x34
+	KeyF7 Key = 0x35
+
+	KeyComma Key = 0x37 // column 9
+	KeyL     Key = 0x38
+	KeyO     Key = 0x39
+	Key9     Key = 0x3A
+	KeyF8    Key = 0x3B
+
+	KeyRightAlt  Key = 0x3C // column 10
+	KeyPeriod    Key = 0x3D
+	KeySemicolon Key = 0x3E
+	KeyP         Key = 0x3F
+	Key0         Key = 0x40
+	KeyF9        Key = 0x41
+
+	KeyRightMeta   Key = 0x42 // column 11
+	KeySlash       Key = 0x43
+	KeyQuote       Key = 0x44
+	KeyLeftBracket Key = 0x45
+	KeyMinus       Key = 0x46
+	KeyF10         Key = 0x47
+
+	KeyMenu         Key = 0x48 // column 12
+	KeyNonUSHash    Key = 0x4A
+	KeyRightBracket Key = 0x4B
+	KeyEqual        Key = 0x4C
+	KeyF11          Key = 0x4D
+
+	KeyRightCtrl  Key = 0x4E // column 13
+	KeyRightShift Key = 0x4F
+	KeyEnter      Key = 0x50
+	KeyBackslash  Key = 0x51
+	KeyBackspace  Key = 0x52
+	KeyF12        Key = 0x53
+)
+
+// Keys of the navigation block.
+const (
+	KeyLeft        Key = 0x54 // column 14
+	KeyDelete      Key = 0x57
+	KeyInsert      Key = 0x58
+	KeyPrintScreen Key = 0x59
+
+	KeyDown       Key = 0x5A // column 15
+	KeyUp         Key = 0x5B
+	KeyEnd        Key = 0x5D
+	KeyHome       Key = 0x5E
+	KeyScrollLock Key = 0x5F
+
+	KeyRight    Key = 0x60 // column 16
+	KeyPageDown Key = 0x63
+	KeyPageUp   Key = 0x64
+	KeyPause    Key = 0x65
+)
+
+// Keys of the number pad.
+const (
+	KeyKP0     Key = 0x66 // column 17
+	KeyKP1     Key = 0x67
+	KeyKP4     Key = 0x68
+	KeyKP7     Key = 0x69
+	KeyNumLock Key = 0x6A
+
+	KeyKP2      Key = 0x6D // column 18
+	KeyKP5      Key = 0x6E
+	KeyKP8      Key = 0x6F
+	KeyKPDivide Key = 0x70
+
+	KeyKPDecimal  Key = 0x72 // column 19
+	KeyKP3        Key = 0x73
+	KeyKP6        Key = 0x74
+	KeyKP9        Key = 0x75
+	KeyKPMultiply Key = 0x76
+
+	KeyKPEnter Key = 0x79 // column 20
+	KeyKPPlus  Key = 0x7B
+	KeyKPMinus Key = 0x7C
+)
+
+var keyNames = map[Key]string{
+	KeyLeftCtrl: "LeftCtrl", KeyLeftShift: "LeftShift", KeyCapsLock: "CapsLock", KeyTab: "Tab", KeyBackquote: "Backquote", KeyEsc: "Esc",
+	KeyLeftMeta: "LeftMeta", KeyNonUSBackslash: "NonUSBackslash", KeyA: "A", KeyQ: "Q", Key1: "1",
+	KeyLeftAlt: "LeftAlt", KeyZ: "Z", KeyS: "S", KeyW: "W", Key2: "2", KeyF1: "F1",
+	KeyX: "X", KeyD: "D", KeyE: "E", Key3: "3", KeyF2: "F2",
+	KeyC: "C", KeyF: "F", KeyR: "R", Key4: "4", KeyF3: "F3",
+	KeyV: "V", KeyG: "G", KeyT: "T", Key5: "5", KeyF4: "F4",
+	KeySpace: "Space", KeyB: "B", KeyH: "H", KeyY: "Y", Key6: "6", KeyF5: "F5",
+	KeyN: "N", KeyJ: "J", KeyU: "U", Key7: "7", KeyF6: "F6",
+	KeyM: "M", KeyK: "K", KeyI: "I", Key8: "8", KeyF7: "F7",
+	KeyComma: "Comma", KeyL: "L", KeyO: "O", Key9: "9", KeyF8: "F8",
+	KeyRightAlt: "RightAlt", KeyPeriod: "Period", KeySemicolon: "Semicolon", KeyP: "P", Key0: "0", KeyF9: "F9",
+	KeyRightMeta: "RightMeta", KeySlash: "Slash", KeyQuote: "Quote", KeyLeftBracket: "LeftBracket", KeyMinus: "Minus", KeyF10: "F10",
+	KeyMenu: "Menu", KeyNonUSHash: "NonUSHash", KeyRightBracket: "RightBracket", KeyEqual: "Equal", KeyF11: "F11",
+	KeyRightCtrl: "RightCtrl", KeyRightShift: "RightShift", KeyEnter: "Enter", KeyBackslash: "Backslash", KeyBackspace: "Backspace", KeyF12: "F12",
+
+	KeyLeft: "Left", KeyDelete: "Delete", KeyInsert: "Insert", KeyPrintScreen: "PrintScreen",
+	KeyDown: "Down", KeyUp: "Up", KeyEnd: "End", KeyHome: "Home", KeyScrollLock: "ScrollLock",
+	KeyRight: "Right", KeyPageDown: "PageDown", KeyPageUp: "PageUp", KeyPause: "Pause",
+
+	KeyKP0: "KP0", KeyKP1: "KP1", KeyKP4: "KP4", KeyKP7: "KP7", KeyNumLock: "NumLock",
+	KeyKP2: "KP2", KeyKP5: "KP5", KeyKP8: "KP8", KeyKPDivide: "KPDivide",
+	KeyKPDecimal: "KPDecimal", KeyKP3: "KP3", KeyKP6: "KP6", KeyKP9: "KP9", KeyKPMultiply: "KPMultiply",
+	KeyKPEnter: "KPEnter", KeyKPPlus: "KPPlus", KeyKPMinus: "KPMinus",
+}
+
+var keysByName = func() map[string]Key {
+	m := make(map[string]Key, len(keyNames))
+	for k, name := range keyNames {
+		m[strings.ToLower(name)] = k
+	}
+	return m
+}()
+
+// String returns the name of the key, e.g. "F1", or "Key(0x07)" if the ID
+// does not have a name.
+func (k Key) String() string {
+	if name, ok := keyNames[k]; ok {
+		return name
+	}
+	return fmt.Sprintf("Key(0x%02X)", uint8(k))
+}
+
+// Valid returns true if k is a valid LED ID, i.e. k <= MaxID.
+func (k Key) Valid() bool {
+	return k <= MaxID
+}
+
+// KeyByName returns the key with the given name. The name is matched
+// case-insensitively, i.e. "F1", "f1" and "esc" are all valid names. Names
+// are the ones returned by Key.String, including the "Key(0x07)" form for
+// IDs without a name.
+func KeyByName(name string) (Key, error) {
+	lower := strings.ToLower(name)
+	if k, ok := keysByName[lower]; ok {
+		return k, nil
+	}
+
+	if strings.HasPrefix(lower, "key(0x") && strings.HasSuffix(lower, ")") {
+		id, err := strconv.ParseUint(lower[6:len(lower)-1], 16, 8)
+		if err == nil && Key(id).Valid() {
+			return Key(id), nil
+		}
+	}
+
+	return 0, fmt.Errorf("unknown key %q", name)
+}
+
+// Keys returns all named keys, ordered by ID.
+func Keys() []Key {
+	keys := make([]Key, 0, len(keyNames))
+	for k := range keyNames {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	return keys
+}
@@ -0,0 +1,67 @@
+package dkb4q
+
+import "testing"
+
+func TestKeyByName(t *testing.T) {
+	cases := []struct {
+		name    string
+		want    Key
+		wantErr bool
+	}{
+		{name: "Esc", want: 0x05},
+		{name: "esc", want: 0x05},
+		{name: "F1", want: 0x11},
+		{name: "F12", want: 0x53},
+		{name: "KP0", want: 0x66},
+		{name: "Key(0x07)", want: 0x07},
+		{name: "key(0x0b)", want: 0x0B},
+		{name: "Key(0x83)", wantErr: true},
+		{name: "Key(0xZZ)", wantErr: true},
+		{name: "F13", wantErr: true},
+		{name: "", wantErr: true},
+	}
+
+	for _, tc := range cases {
+		got, err := KeyByName(tc.name)
+		if gotErr := err != nil; gotErr != tc.wantErr {
+			t.Errorf("KeyByName(%q) = %v, want error %v", tc.name, err, tc.wantErr)
+		}
+		if tc.wantErr {
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("KeyByName(%q) = %#x, want %#x", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestKey_String(t *testing.T) {
+	for id := 0; id <= MaxID; id++ {
+		k := Key(id)
+
+		got, err := KeyByName(k.String())
+		if err != nil {
+			t.Errorf("KeyByName(%q) = %v", k.String(), err)
+			continue
+		}
+		if got != k {
+			t.Errorf("KeyByName(%q) = %#x, want %#x", k.String(), got, k)
+		}
+	}
+}
+
+func TestKeys(t *testing.T) {
+	keys := Keys()
+	if len(keys) != len(keyNames) {
+		t.Errorf("len(Keys()) = %d, want %d", len(keys), len(keyNames))
+	}
+
+	for i, k := range keys {
+		if !k.Valid() {
+			t.Errorf("Keys()[%d] = %#x, want <= %#x", i, k, MaxID)
+		}
+		if i > 0 && keys[i-1] >= k {
+			t.Errorf("Keys() is not sorted: %v >= %v", keys[i-1], k)
+		}
+	}
+}
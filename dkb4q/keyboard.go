@@ -5,13 +5,13 @@
 package dkb4q
 
 import (
-	"bytes"
 	"context"
 	"errors"
 	"fmt"
+	"sync"
 
+	"github.com/octo/das/internal/usb"
 	"github.com/octo/retry"
-	"github.com/zserge/hid"
 )
 
 const (
@@ -19,13 +19,61 @@ const (
 	MaxID = 130
 )
 
-// Keyboard represents the connection to a keyboard.
+// maxSetReportAttempts is the number of times a report is sent before
+// giving up.
+const maxSetReportAttempts = 5
+
+// maxGetReportAttempts is the number of all-zero reports read while waiting
+// for a response before giving up.
+const maxGetReportAttempts = 10
+
+// Device is the HID device used to talk to the keyboard. It is implemented
+// by hid.Device and by fake.HID, which allows testing code using Keyboard
+// without the hardware.
+type Device interface {
+	Close()
+	SetReport(int, []byte) error
+	GetReport(int) ([]byte, error)
+}
+
+// Keyboard represents the connection to a keyboard. A Keyboard returned by
+// Open or New is safe for concurrent use: commands are executed one at a time.
+// Concurrent commands are not guaranteed to be executed in the order they
+// were issued. The zero value is a closed Keyboard.
 type Keyboard struct {
-	dev interface {
-		Close()
-		SetReport(int, []byte) error
-		GetReport(int) ([]byte, error)
+	// mu serializes commands. Messages are split into multiple reports,
+	// which must not interleave.
+	mu        *sync.Mutex
+	dev       Device
+	info      *DeviceInfo
+	input     InputDevice
+	tracer    Tracer
+	pipelined bool
+	// committed holds the states committed via this Keyboard.
+	committed map[Key]State
+	// pending holds the states staged by SetColor and SetEffect.
+	pending map[Key]State
+}
+
+// Option is an option for Open and New.
+type Option func(*Keyboard)
+
+// New returns a Keyboard talking to dev. Use Open to connect to a keyboard
+// attached via USB.
+//
+// The connection to the keyboard should be closed with Close(), which closes
+// dev.
+func New(dev Device, opts ...Option) Keyboard {
+	kb := Keyboard{
+		mu:        &sync.Mutex{},
+		dev:       dev,
+		committed: make(map[Key]State),
+		pending:   make(map[Key]State),
 	}
+	for _, opt := range opts {
+		opt(&kb)
+	}
+	return kb
 }
 
 // Open scans USB devices for a "Das Keyboard" by looking for the vendor ID
@@ -33,54 +81,68 @@ type Keyboard struct {
 // opened. If no device could be opened, an error is returned.
 //
 // The connection to the keyboard should be closed with Close().
-func Open() (Keyboard, error) {
-	const vendorID = 0x24F0
+func Open(opts ...Option) (Keyboard, error) {
+	return OpenWith(nil, opts...)
+}
 
-	var (
-		device  hid.Device
-		lastErr error
-	)
-	hid.UsbWalk(func(dev hid.Device) {
-		if device != nil || dev.Info().Vendor != vendorID {
-			return
+// OpenWith is like Open, but only considers devices accepted by sel. A nil
+// Selector accepts all devices.
+//
+// If no device could be opened, the returned error is an *OpenError listing
+// the skipped devices. errors.Is(err, ErrNotFound) is true for this error.
+func OpenWith(sel Selector, opts ...Option) (Keyboard, error) {
+	kb := New(nil, opts...)
+
+	dev, info, err := usb.Open(func(info DeviceInfo) error {
+		kb.trace().Device(info)
+		if info.Interface != ledInterface {
+			return fmt.Errorf("interface %d, want %d", info.Interface, ledInterface)
 		}
-
-		fmt.Println("dev.Info() =", dev.Info())
-		if dev.Info().Interface != 1 {
-			return
+		if sel != nil && !sel(info) {
+			return errNotSelected
 		}
+		return nil
+	})
+	if err != nil {
+		return Keyboard{}, err
+	}
 
-		if err := dev.Open(); err != nil {
-			lastErr = err
-			return
-		}
+	kb.dev = dev
+	kb.info = &info
+	return kb, nil
+}
 
-		device = dev
-		return
-	})
+var errNotOpen = errors.New("connection to keyboard not open")
 
-	if device == nil {
-		if lastErr != nil {
-			return Keyboard{}, lastErr
-		}
-		return Keyboard{}, errors.New("no Das Keyboard device found")
+// lock acquires kb.mu. It returns an error if kb has not been created by Open
+// or New.
+func (kb *Keyboard) lock() error {
+	if kb.mu == nil {
+		return errNotOpen
 	}
+	kb.mu.Lock()
+	return nil
+}
 
-	kb := Keyboard{
-		dev: device,
+// Close closes the connection to the keyboard, including the input device set
+// with the Input option.
+func (kb *Keyboard) Close() error {
+	if err := kb.lock(); err != nil {
+		return err
 	}
+	defer kb.mu.Unlock()
 
-	return kb, nil
-}
+	if kb.input != nil {
+		kb.input.Close()
+		kb.input = nil
+	}
 
-// Close closes the connection to the keyboard.
-func (kb *Keyboard) Close() error {
 	defer func() {
 		kb.dev = nil
 	}()
 
 	if kb.dev == nil {
-		return fmt.Errorf("connection to keyboard not open")
+		return errNotOpen
 	}
 
 	kb.dev.Close()
@@ -94,9 +156,21 @@ func (kb *Keyboard) setReport(ctx context.Context, data []byte) error {
 
 	for i := 0; i < len(data); i += 7 {
 		payload := append([]byte{0x01}, data[i:i+7]...)
+		attempts := 0
 		err := retry.Do(ctx, func(_ context.Context) error {
-			fmt.Printf("-> SetReport(1, %#v)\n", payload)
-			return kb.dev.SetReport(1, payload)
+			err := kb.dev.SetReport(1, payload)
+			kb.trace().SetReport(1, payload, err)
+			if err == nil {
+				return nil
+			}
+
+			// Give up eventually, e.g. if the device has been
+			// unplugged.
+			attempts++
+			if attempts >= maxSetReportAttempts {
+				return retry.Abort(err)
+			}
+			return err
 		})
 		if err != nil {
 			return err
@@ -106,56 +180,60 @@ func (kb *Keyboard) setReport(ctx context.Context, data []byte) error {
 	return nil
 }
 
+// maxPendingReads is the number of reads getReports performs to complete a
+// partial report. A stray 0xED byte looks like the beginning of a report; the
+// limit ensures getReports does not wait for the rest indefinitely.
+const maxPendingReads = 4
+
+// getReports reads reports from the device until no partial report remains.
+// A partial report which is not completed within maxPendingReads reads is
+// discarded. Bytes that are not part of a valid report are skipped and
+// reported to the Tracer. If the device does not respond within
+// maxGetReportAttempts reads, errNoReport is returned. If no valid report was
+// received at all, an error is returned.
 func (kb *Keyboard) getReports(ctx context.Context) ([][]byte, error) {
 	var (
-		buf     bytes.Buffer
+		dec     reportDecoder
 		reports [][]byte
 	)
 
-	for {
-		for buf.Len() < 2 {
-			data, err := kb.getReport(ctx)
-			if err != nil {
-				return nil, err
-			}
-			buf.Write(data)
-		}
-
-		reportType, err := buf.ReadByte()
-		if err != nil {
-			return nil, err
-		}
+	data, _, err := kb.getReport(ctx, maxGetReportAttempts)
+	if err != nil {
+		return nil, err
+	}
 
-		reportLen, err := buf.ReadByte()
-		if err != nil {
+	for pendingReads := 0; ; {
+		if err != nil && !errors.Is(err, errNoReport) {
 			return nil, err
 		}
-		if reportLen < 2 {
-			return nil, fmt.Errorf("invalid length %d, want at least 2", reportLen)
-		}
+		dec.Write(data)
 
-		for buf.Len() < int(reportLen) {
-			data, err := kb.getReport(ctx)
-			if err != nil {
-				return nil, err
+		for {
+			report, ok := dec.Next()
+			if !ok {
+				break
 			}
-			buf.Write(data)
+			reports = append(reports, report)
 		}
 
-		payload := buf.Next(int(reportLen))
-		report := append([]byte{reportType, reportLen}, payload...)
-
-		gotParity := payload[len(payload)-1]
-		wantParity := xorAll(report[:len(report)-1])
-		if gotParity != wantParity {
-			return nil, fmt.Errorf("parity mismatch: got %#x, want %#x", gotParity, wantParity)
+		if !dec.Pending() {
+			break
 		}
-
-		reports = append(reports, report)
-
-		if isZero(buf.Bytes()) {
+		if pendingReads >= maxPendingReads {
+			dec.DiscardPending()
 			break
 		}
+
+		var n int
+		data, n, err = kb.getReport(ctx, maxPendingReads-pendingReads)
+		pendingReads += n
+	}
+
+	if len(dec.discarded) != 0 {
+		kb.trace().Discarded(dec.discarded)
+	}
+	if len(reports) == 0 {
+		return nil, fmt.Errorf("no valid report received, discarded %#v", dec.discarded)
 	}
 
 	return reports, nil
@@ -163,13 +241,20 @@ func (kb *Keyboard) getReports(ctx context.Context) ([][]byte, error) {
 
 var errNoReport = errors.New("no report available")
 
-func (kb *Keyboard) getReport(ctx context.Context) ([]byte, error) {
-	var ret []byte
+// getReport reads a non-zero report from the device and returns it with the
+// number of reads performed. All-zero reports are retried until ctx is
+// cancelled or, if maxAttempts is positive, until maxAttempts reads have been
+// performed, in which case errNoReport is returned.
+func (kb *Keyboard) getReport(ctx context.Context, maxAttempts int) ([]byte, int, error) {
+	var (
+		ret      []byte
+		attempts int
+	)
 	cb := func(_ context.Context) error {
-		fmt.Printf("<- GetReport(1) = ")
+		attempts++
 		data, err := kb.dev.GetReport(1)
 		if err != nil {
-			fmt.Println(err)
+			kb.trace().GetReport(1, nil, err)
 			return retry.Abort(err)
 		}
 
@@ -183,14 +268,17 @@ func (kb *Keyboard) getReport(ctx context.Context) ([]byte, error) {
 		}
 
 		if isZero(data) {
-			fmt.Println(errNoReport)
+			kb.trace().GetReport(1, nil, errNoReport)
+			if maxAttempts > 0 && attempts >= maxAttempts {
+				return retry.Abort(errNoReport)
+			}
 			return errNoReport
 		}
-		fmt.Printf("%#v\n", data)
+		kb.trace().GetReport(1, data, nil)
 		ret = data
 		return nil
 	}
 
 	err := retry.Do(ctx, cb)
-	return ret, err
+	return ret, attempts, err
 }
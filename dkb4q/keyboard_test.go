@@ -2,7 +2,10 @@ package dkb4q
 
 import (
 	"context"
+	"errors"
+	"image/color"
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/octo/das/dkb4q/fake"
@@ -99,9 +102,7 @@ func TestGetReports(t *testing.T) {
 					Data: res,
 				})
 			}
-			kb := &Keyboard{
-				dev: &hid,
-			}
+			kb := New(&hid)
 			defer kb.Close()
 
 			got, err := kb.getReports(ctx)
@@ -118,3 +119,278 @@ func TestGetReports(t *testing.T) {
 		})
 	}
 }
+
+func TestKeyboard_Concurrent(t *testing.T) {
+	var (
+		ctx = context.Background()
+		hid fake.HID
+		s   = State{ID: KeyEsc, IdleEffect: SetColor, IdleColor: color.NRGBA{R: 0xFF}}
+	)
+
+	const n = 10
+	for i := 0; i < n; i++ {
+		expectSetState(&hid, s)
+	}
+
+	kb := New(&hid)
+	defer kb.Close()
+
+	// If reports of different calls interleaved, fake.HID would see
+	// unexpected reports.
+	errs := make(chan error)
+	for i := 0; i < n; i++ {
+		go func() {
+			errs <- kb.SetState(ctx, s)
+		}()
+	}
+	for i := 0; i < n; i++ {
+		if err := <-errs; err != nil {
+			t.Errorf("Keyboard.SetState() = %v", err)
+		}
+	}
+}
+
+func TestKeyboard_NotOpen(t *testing.T) {
+	ctx := context.Background()
+	s := State{ID: KeyEsc, IdleEffect: SetColor, IdleColor: color.NRGBA{R: 0xFF}}
+
+	var zero Keyboard
+	if err := zero.SetState(ctx, s); err == nil {
+		t.Error("SetState() on a zero Keyboard succeeded, want error")
+	}
+	if err := zero.SetColor(ctx, color.NRGBA{R: 0xFF}, "Esc"); err == nil {
+		t.Error("SetColor() on a zero Keyboard succeeded, want error")
+	}
+	if _, ok := zero.LastCommitted(KeyEsc); ok {
+		t.Error("LastCommitted() on a zero Keyboard = ok, want not ok")
+	}
+	if err := zero.Close(); err == nil {
+		t.Error("Close() on a zero Keyboard succeeded, want error")
+	}
+
+	closed := New(&fake.HID{})
+	closed.Close()
+	if err := closed.SetState(ctx, s); err == nil {
+		t.Error("SetState() on a closed Keyboard succeeded, want error")
+	}
+}
+
+func TestGetReports_Faults(t *testing.T) {
+	ack := []byte{0xED, 0x03, 0x78, 0x00, 0x96, 0x00, 0x00, 0x00}
+
+	cases := []struct {
+		title     string
+		responses [][]byte
+		faulty    *fake.Faulty
+		want      [][]byte
+		wantErr   error // nil: success; errAny: any error
+	}{
+		{
+			title:     "delay",
+			responses: [][]byte{ack},
+			faulty:    &fake.Faulty{Delay: time.Millisecond},
+			want:      [][]byte{ack[:5]},
+		},
+		{
+			// Errors returned by GetReport are not retried.
+			title:     "transient error",
+			responses: [][]byte{ack},
+			faulty:    &fake.Faulty{TransientErrors: 1},
+			wantErr:   fake.ErrTransient,
+		},
+		{
+			title: "permanent error",
+			responses: [][]byte{
+				{0xED, 0x03, 0x78},
+				{0x00, 0x96, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+			},
+			faulty:  &fake.Faulty{FailAfter: 1},
+			wantErr: fake.ErrPermanent,
+		},
+		{
+			// All-zero reports are retried until a response arrives.
+			title:     "dropped response",
+			responses: [][]byte{ack, ack},
+			faulty:    &fake.Faulty{DropResponses: 1},
+			want:      [][]byte{ack[:5]},
+		},
+		{
+			// A device that never responds is not waited for forever.
+			title:     "no response",
+			responses: zeroResponses(maxGetReportAttempts + 1),
+			faulty:    &fake.Faulty{},
+			wantErr:   errNoReport,
+		},
+		{
+			title:     "corrupted parity",
+			responses: [][]byte{ack},
+			faulty: &fake.Faulty{Corrupt: func(data []byte) []byte {
+				data[4] ^= 0xFF
+				return data
+			}},
+			wantErr: errAny,
+		},
+		{
+			title:     "corrupted length",
+			responses: [][]byte{ack},
+			faulty: &fake.Faulty{Corrupt: func(data []byte) []byte {
+				data[1] = 0x01
+				return data
+			}},
+			wantErr: errAny,
+		},
+		{
+			// Stray bytes around the response are skipped.
+			title:     "stray byte",
+			responses: [][]byte{ack},
+			faulty: &fake.Faulty{Corrupt: func(data []byte) []byte {
+				data[6] = 0x9A
+				return data
+			}},
+			want: [][]byte{ack[:5]},
+		},
+		{
+			title:     "stray byte before response",
+			responses: [][]byte{ack},
+			faulty: &fake.Faulty{Corrupt: func(data []byte) []byte {
+				return append([]byte{0x9A}, data...)
+			}},
+			want: [][]byte{ack[:5]},
+		},
+		{
+			title:     "partial reports",
+			responses: [][]byte{{0xED}, {0x03, 0x78}, {0x00, 0x96}},
+			faulty:    &fake.Faulty{},
+			want:      [][]byte{ack[:5]},
+		},
+		{
+			// A stray report header is discarded when the
+			// following reads return no data ...
+			title:     "stray header after response",
+			responses: [][]byte{ack, make([]byte, 8), make([]byte, 8), make([]byte, 8), make([]byte, 8)},
+			faulty: &fake.Faulty{Corrupt: func(data []byte) []byte {
+				if data[0] == 0xED {
+					data[5], data[6] = 0xED, 0x05
+				}
+				return data
+			}},
+			want: [][]byte{ack[:5]},
+		},
+		{
+			// ... or data which does not complete the report.
+			title:     "stray header with garbage",
+			responses: [][]byte{ack, {0x01}, {0x01}, {0x01}, {0x01}},
+			faulty: &fake.Faulty{Corrupt: func(data []byte) []byte {
+				if data[0] == 0xED {
+					data[5], data[6] = 0xED, 0x20
+				}
+				return data
+			}},
+			want: [][]byte{ack[:5]},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.title, func(t *testing.T) {
+			var (
+				ctx = context.Background()
+				hid fake.HID
+			)
+
+			for _, res := range tc.responses {
+				hid.WantGetReport = append(hid.WantGetReport, fake.Report{
+					ID:   1,
+					Data: res,
+				})
+			}
+			dev := tc.faulty
+			dev.Device = &hid
+
+			kb := New(dev)
+			defer kb.Close()
+
+			got, err := kb.getReports(ctx)
+			switch {
+			case tc.wantErr == nil && err != nil:
+				t.Fatalf("getReports() = %v, want success", err)
+			case tc.wantErr == errAny && err == nil:
+				t.Fatalf("getReports() = %v, want error", got)
+			case tc.wantErr != nil && tc.wantErr != errAny && !errors.Is(err, tc.wantErr):
+				t.Fatalf("getReports() = %v, want %v", err, tc.wantErr)
+			}
+			if tc.wantErr != nil {
+				return
+			}
+
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("getReports() differs (+got/-want):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestKeyboard_SetStateFaults(t *testing.T) {
+	s := State{ID: KeyEsc, IdleEffect: SetColor, IdleColor: color.NRGBA{R: 0xFF}}
+
+	cases := []struct {
+		title   string
+		faulty  *fake.Faulty
+		wantErr error
+	}{
+		{
+			// SetReport errors are retried ...
+			title:  "transient errors",
+			faulty: &fake.Faulty{TransientErrors: maxSetReportAttempts - 1},
+		},
+		{
+			// ... but not indefinitely.
+			title:   "too many transient errors",
+			faulty:  &fake.Faulty{TransientErrors: maxSetReportAttempts},
+			wantErr: fake.ErrTransient,
+		},
+		{
+			title:   "permanent error",
+			faulty:  &fake.Faulty{FailAfter: 3},
+			wantErr: fake.ErrPermanent,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.title, func(t *testing.T) {
+			ctx := context.Background()
+			sim := fake.NewSimulator()
+
+			dev := tc.faulty
+			dev.Device = sim
+
+			kb := New(dev)
+			defer kb.Close()
+
+			err := kb.SetState(ctx, s)
+			if tc.wantErr != nil {
+				if !errors.Is(err, tc.wantErr) {
+					t.Errorf("Keyboard.SetState() = %v, want %v", err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Keyboard.SetState() = %v", err)
+			}
+
+			if _, ok := sim.Committed(uint8(s.ID)); !ok {
+				t.Errorf("key %v has not been committed", s.ID)
+			}
+		})
+	}
+}
+
+// errAny is used in tests to indicate that any error is expected.
+var errAny = errors.New("any error")
+
+func zeroResponses(n int) [][]byte {
+	res := make([][]byte, n)
+	for i := range res {
+		res[i] = make([]byte, 8)
+	}
+	return res
+}
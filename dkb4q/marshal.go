@@ -0,0 +1,148 @@
+package dkb4q
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MarshalText implements encoding.TextMarshaler. The effect is encoded by
+// name, e.g. "breathe".
+func (e IdleEffect) MarshalText() ([]byte, error) {
+	return []byte(e.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (e *IdleEffect) UnmarshalText(text []byte) error {
+	v, err := ParseIdleEffect(string(text))
+	if err != nil {
+		return err
+	}
+	*e = v
+	return nil
+}
+
+// activeEffectJSON is the JSON encoding of ActiveEffect. Effects created with
+// SetColorActive, BlinkActive and BreatheActive are encoded by name, with
+// their options:
+//
+//	{"effect": "none"}
+//	{"effect": "set_color", "duration": "1.89s"}
+//	{"effect": "blink", "cycleDuration": "1.05s", "cycleCount": 3}
+//	{"effect": "breathe", "cycleCount": 3}
+//
+// All other effects are encoded as the hex encoded effect ID and arguments,
+// e.g. {"raw": "1e07d000"}, so that encoding is lossless.
+type activeEffectJSON struct {
+	Effect        string `json:"effect,omitempty"`
+	Duration      string `json:"duration,omitempty"`
+	CycleDuration string `json:"cycleDuration,omitempty"`
+	CycleCount    *uint8 `json:"cycleCount,omitempty"`
+	Raw           string `json:"raw,omitempty"`
+}
+
+// MarshalJSON implements json.Marshaler.
+func (ae ActiveEffect) MarshalJSON() ([]byte, error) {
+	var v activeEffectJSON
+	switch ae.id {
+	case None.id:
+		v.Effect = "none"
+	case setColorActiveID:
+		v.Effect = "set_color"
+		v.Duration = (time.Duration(ae.arg0) * 270 * time.Millisecond).String()
+	case byte(Blink):
+		v.Effect = "blink"
+		cycle := uint(ae.arg0)<<8 | uint(ae.arg1)
+		v.CycleDuration = (time.Duration(cycle) * (1050 * time.Millisecond) / 500).String()
+		v.CycleCount = &ae.arg2
+	case byte(Breathe):
+		v.Effect = "breathe"
+		v.CycleCount = &ae.arg2
+	}
+
+	// Only use the named encoding if it decodes to the same effect.
+	if v.Effect != "" {
+		if got, err := v.activeEffect(); err != nil || got != ae {
+			v = activeEffectJSON{}
+		}
+	}
+	if v.Effect == "" {
+		v.Raw = hex.EncodeToString([]byte{ae.id, ae.arg0, ae.arg1, ae.arg2})
+	}
+
+	return json.Marshal(v)
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (ae *ActiveEffect) UnmarshalJSON(data []byte) error {
+	var v activeEffectJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+
+	got, err := v.activeEffect()
+	if err != nil {
+		return err
+	}
+	*ae = got
+	return nil
+}
+
+func (v activeEffectJSON) activeEffect() (ActiveEffect, error) {
+	if v.Raw != "" {
+		b, err := hex.DecodeString(v.Raw)
+		if err != nil || len(b) != 4 {
+			return ActiveEffect{}, fmt.Errorf("invalid raw active effect %q, want four hex encoded bytes", v.Raw)
+		}
+		return ActiveEffect{id: b[0], arg0: b[1], arg1: b[2], arg2: b[3]}, nil
+	}
+
+	var opts []ActiveEffectOption
+	if v.Duration != "" {
+		d, err := time.ParseDuration(v.Duration)
+		if err != nil {
+			return ActiveEffect{}, err
+		}
+		opts = append(opts, EffectDuration(d))
+	}
+	if v.CycleDuration != "" {
+		d, err := time.ParseDuration(v.CycleDuration)
+		if err != nil {
+			return ActiveEffect{}, err
+		}
+		opts = append(opts, CycleDuration(d))
+	}
+	if v.CycleCount != nil {
+		opts = append(opts, CycleCount(*v.CycleCount))
+	}
+
+	switch strings.ToLower(v.Effect) {
+	case "", "none":
+		return None, nil
+	case "set_color":
+		return SetColorActive(opts...), nil
+	case "blink":
+		return BlinkActive(opts...), nil
+	case "breathe":
+		return BreatheActive(opts...), nil
+	default:
+		return ActiveEffect{}, fmt.Errorf("unknown active effect %q", v.Effect)
+	}
+}
+
+// parseIdleEffectID parses the "IdleEffect(0x07)" form returned by
+// IdleEffect.String for effects without a name.
+func parseIdleEffectID(name string) (IdleEffect, bool) {
+	lower := strings.ToLower(name)
+	if !strings.HasPrefix(lower, "idleeffect(0x") || !strings.HasSuffix(lower, ")") {
+		return 0, false
+	}
+	id, err := strconv.ParseUint(lower[13:len(lower)-1], 16, 8)
+	if err != nil {
+		return 0, false
+	}
+	return IdleEffect(id), true
+}
@@ -0,0 +1,95 @@
+package dkb4q
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestActiveEffect_JSON(t *testing.T) {
+	cases := []struct {
+		effect ActiveEffect
+		want   string
+	}{
+		{None, `{"effect":"none"}`},
+		{SetColorActive(), `{"effect":"set_color","duration":"1.89s"}`},
+		{SetColorActive(EffectDuration(540 * time.Millisecond)), `{"effect":"set_color","duration":"540ms"}`},
+		{BlinkActive(), `{"effect":"blink","cycleDuration":"1.05s","cycleCount":3}`},
+		{BlinkActive(CycleCount(0), CycleDuration(2*time.Second)), `{"effect":"blink","cycleDuration":"1.9992s","cycleCount":0}`},
+		{BreatheActive(CycleCount(7)), `{"effect":"breathe","cycleCount":7}`},
+		// effects that cannot be created with the constructors and options.
+		{ActiveEffect{id: byte(ColorCycle), arg0: 0x01}, `{"raw":"14010000"}`},
+		{ActiveEffect{id: setColorActiveID, arg1: 0xD0}, `{"raw":"1e00d000"}`},
+		{ActiveEffect{id: byte(Breathe), arg2: 0x02}, `{"raw":"08000002"}`},
+	}
+
+	for _, tc := range cases {
+		data, err := json.Marshal(tc.effect)
+		if err != nil {
+			t.Errorf("json.Marshal(%#v) = %v", tc.effect, err)
+			continue
+		}
+		if got := string(data); got != tc.want {
+			t.Errorf("json.Marshal(%#v) = %s, want %s", tc.effect, got, tc.want)
+		}
+
+		var got ActiveEffect
+		if err := json.Unmarshal(data, &got); err != nil {
+			t.Errorf("json.Unmarshal(%s) = %v", data, err)
+			continue
+		}
+		if diff := cmp.Diff(tc.effect, got, cmp.AllowUnexported(ActiveEffect{})); diff != "" {
+			t.Errorf("json.Unmarshal(%s) differs (+got/-want):\n%s", data, diff)
+		}
+	}
+}
+
+func TestActiveEffect_UnmarshalJSON(t *testing.T) {
+	cases := []struct {
+		data    string
+		want    ActiveEffect
+		wantErr bool
+	}{
+		{`{}`, None, false},
+		{`{"effect":"Blink"}`, BlinkActive(), false},
+		{`{"effect":"breathe","cycleCount":5}`, BreatheActive(CycleCount(5)), false},
+		{`{"effect":"set_color","duration":"3s"}`, SetColorActive(EffectDuration(3 * time.Second)), false},
+		{`{"effect":"sparkle"}`, ActiveEffect{}, true},
+		{`{"effect":"set_color","duration":"soon"}`, ActiveEffect{}, true},
+		{`{"raw":"1e07"}`, ActiveEffect{}, true},
+		{`{"raw":"zz07d000"}`, ActiveEffect{}, true},
+	}
+
+	for _, tc := range cases {
+		var got ActiveEffect
+		err := json.Unmarshal([]byte(tc.data), &got)
+		if gotErr := err != nil; gotErr != tc.wantErr {
+			t.Errorf("json.Unmarshal(%s) = %v, want error %v", tc.data, err, tc.wantErr)
+			continue
+		}
+		if diff := cmp.Diff(tc.want, got, cmp.AllowUnexported(ActiveEffect{})); diff != "" {
+			t.Errorf("json.Unmarshal(%s) differs (+got/-want):\n%s", tc.data, diff)
+		}
+	}
+}
+
+func TestIdleEffect_Text(t *testing.T) {
+	for _, e := range []IdleEffect{SetColor, Breathe, Blink, ColorCycle, IdleEffect(0x07)} {
+		text, err := e.MarshalText()
+		if err != nil {
+			t.Errorf("%v.MarshalText() = %v", e, err)
+			continue
+		}
+
+		var got IdleEffect
+		if err := got.UnmarshalText(text); err != nil {
+			t.Errorf("UnmarshalText(%q) = %v", text, err)
+			continue
+		}
+		if got != e {
+			t.Errorf("UnmarshalText(%q) = %v, want %v", text, got, e)
+		}
+	}
+}
@@ -34,3 +34,86 @@ func isZero(data []byte) bool {
 	}
 	return true
 }
+
+// maxReportLen is the maximum length of a report, as encoded in the report's
+// second byte. Responses are much shorter; the limit helps to reject garbage
+// quickly.
+const maxReportLen = 32
+
+// reportDecoder decodes a stream of response reports. It scans for valid
+// reports, i.e. a known report type, a plausible length and a matching
+// parity byte, and skips everything else. Zero bytes between reports are
+// considered padding.
+type reportDecoder struct {
+	buf []byte
+	// discarded holds all non-zero bytes that were skipped.
+	discarded []byte
+}
+
+// Write appends data to the decoder's buffer.
+func (d *reportDecoder) Write(data []byte) {
+	d.buf = append(d.buf, data...)
+}
+
+// Next returns the next valid report in the buffer. It returns false if the
+// buffer is empty or only holds the beginning of a report.
+func (d *reportDecoder) Next() ([]byte, bool) {
+	for len(d.buf) > 0 {
+		if d.buf[0] == 0x00 {
+			d.buf = d.buf[1:]
+			continue
+		}
+		if d.buf[0] != responseReport {
+			d.discard()
+			continue
+		}
+
+		if len(d.buf) < 2 {
+			return nil, false
+		}
+		reportLen := int(d.buf[1])
+		if reportLen < 2 || reportLen > maxReportLen {
+			d.discard()
+			continue
+		}
+
+		if len(d.buf) < 2+reportLen {
+			return nil, false
+		}
+		report := d.buf[:2+reportLen]
+		if report[len(report)-1] != xorAll(report[:len(report)-1]) {
+			d.discard()
+			continue
+		}
+
+		d.buf = d.buf[len(report):]
+		return append([]byte{}, report...), true
+	}
+
+	return nil, false
+}
+
+// Pending returns true if the buffer holds the beginning of a report, i.e.
+// more data is required. Call Next until it returns false before calling
+// Pending.
+func (d *reportDecoder) Pending() bool {
+	return !isZero(d.buf)
+}
+
+// DiscardPending discards the partial report at the beginning of the buffer,
+// e.g. because the rest of the report never arrived.
+func (d *reportDecoder) DiscardPending() {
+	for len(d.buf) > 0 {
+		if d.buf[0] == 0x00 {
+			d.buf = d.buf[1:]
+			continue
+		}
+		d.discard()
+	}
+}
+
+// discard skips the first byte of the buffer.
+func (d *reportDecoder) discard() {
+	d.discarded = append(d.discarded, d.buf[0])
+	d.buf = d.buf[1:]
+}
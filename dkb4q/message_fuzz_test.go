@@ -0,0 +1,86 @@
+//go:build go1.18
+// +build go1.18
+
+package dkb4q
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/octo/das/dkb4q/fake"
+)
+
+// FuzzReportRoundTrip checks that every report produced by encodeReport is
+// decoded by reportDecoder, even when preceded by noise.
+func FuzzReportRoundTrip(f *testing.F) {
+	f.Add([]byte{0x78, 0x00}, []byte{})
+	f.Add([]byte{0x78, 0x03, 0x05, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}, []byte{0x9A})
+	f.Add([]byte{0x78, 0x0A}, []byte{0x00, 0x03, 0x78, 0x00})
+
+	f.Fuzz(func(t *testing.T, data, noise []byte) {
+		// Reports hold at least a command and the parity byte.
+		if len(data) == 0 {
+			return
+		}
+		if len(data)+1 > maxReportLen {
+			data = data[:maxReportLen-1]
+		}
+		// Noise must not contain the start of a report.
+		noise = bytes.ReplaceAll(noise, []byte{responseReport}, []byte{0x00})
+
+		enc := encodeReport(responseReport, data)
+		want := enc[:2+len(data)+1]
+
+		var dec reportDecoder
+		dec.Write(noise)
+		dec.Write(enc)
+
+		got, ok := dec.Next()
+		if !ok {
+			t.Fatalf("Next() did not return a report; noise = %#v, encoded = %#v", noise, enc)
+		}
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Errorf("report differs (+got/-want):\n%s", diff)
+		}
+		if dec.Pending() {
+			t.Errorf("Pending() = true after decoding %#v", enc)
+		}
+	})
+}
+
+// FuzzGetReports checks that getReports does not panic on arbitrary input and
+// only returns reports with valid parity.
+func FuzzGetReports(f *testing.F) {
+	f.Add([]byte{0xED, 0x03, 0x78, 0x00, 0x96, 0x00, 0x00, 0x00})
+	f.Add([]byte{0xED, 0x03, 0x78, 0x00, 0xEE, 0x00, 0x00, 0x00})
+	f.Add([]byte{0x9A, 0xED, 0x03, 0x78, 0x00, 0x96, 0x00, 0x00})
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if isZero(data) {
+			// getReport waits for a non-zero report.
+			return
+		}
+
+		hid := fake.HID{
+			WantGetReport: []fake.Report{{ID: 1, Data: data}},
+		}
+		kb := New(&hid)
+		defer kb.Close()
+
+		reports, err := kb.getReports(context.Background())
+		if err != nil {
+			return
+		}
+
+		for _, r := range reports {
+			if len(r) < 4 || int(r[1]) != len(r)-2 {
+				t.Errorf("invalid report length: %#v", r)
+			}
+			if got, want := r[len(r)-1], xorAll(r[:len(r)-1]); got != want {
+				t.Errorf("parity mismatch in %#v: got %#x, want %#x", r, got, want)
+			}
+		}
+	})
+}
@@ -40,3 +40,90 @@ func TestEncodeReport(t *testing.T) {
 		}
 	}
 }
+
+func TestReportDecoder(t *testing.T) {
+	ack := []byte{0xED, 0x03, 0x78, 0x00, 0x96}
+
+	cases := []struct {
+		title         string
+		in            [][]byte
+		want          [][]byte
+		wantDiscarded []byte
+		wantPending   bool
+	}{
+		{
+			title: "single report",
+			in:    [][]byte{{0xED, 0x03, 0x78, 0x00, 0x96, 0x00, 0x00, 0x00}},
+			want:  [][]byte{ack},
+		},
+		{
+			title: "split report",
+			in:    [][]byte{{0xED, 0x03}, {0x78, 0x00, 0x96}},
+			want:  [][]byte{ack},
+		},
+		{
+			title:       "partial report",
+			in:          [][]byte{{0xED, 0x03, 0x78, 0x00, 0x96, 0xED, 0x03, 0x78}},
+			want:        [][]byte{ack},
+			wantPending: true,
+		},
+		{
+			title:         "leading garbage",
+			in:            [][]byte{{0x9A, 0x01, 0xED, 0x03, 0x78, 0x00, 0x96, 0x00}},
+			want:          [][]byte{ack},
+			wantDiscarded: []byte{0x9A, 0x01},
+		},
+		{
+			title:         "garbage between reports",
+			in:            [][]byte{{0xED, 0x03, 0x78, 0x00, 0x96, 0x00, 0x9A, 0x00}, {0xED, 0x03, 0x78, 0x00, 0x96}},
+			want:          [][]byte{ack, ack},
+			wantDiscarded: []byte{0x9A},
+		},
+		{
+			title:         "parity mismatch",
+			in:            [][]byte{{0xED, 0x03, 0x78, 0x00, 0xEE, 0xED, 0x03, 0x78, 0x00, 0x96}},
+			want:          [][]byte{ack},
+			wantDiscarded: []byte{0xED, 0x03, 0x78, 0xEE},
+		},
+		{
+			title:         "invalid length",
+			in:            [][]byte{{0xED, 0x00, 0x78, 0x00, 0x95, 0x00, 0x00, 0x00}},
+			wantDiscarded: []byte{0xED, 0x78, 0x95},
+		},
+		{
+			title:         "excessive length",
+			in:            [][]byte{{0xED, 0xFF, 0xED, 0x03, 0x78, 0x00, 0x96}},
+			want:          [][]byte{ack},
+			wantDiscarded: []byte{0xED, 0xFF},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.title, func(t *testing.T) {
+			var (
+				dec reportDecoder
+				got [][]byte
+			)
+			for _, data := range tc.in {
+				dec.Write(data)
+				for {
+					report, ok := dec.Next()
+					if !ok {
+						break
+					}
+					got = append(got, report)
+				}
+			}
+
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("reports differ (+got/-want):\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.wantDiscarded, dec.discarded); diff != "" {
+				t.Errorf("discarded bytes differ (+got/-want):\n%s", diff)
+			}
+			if got := dec.Pending(); got != tc.wantPending {
+				t.Errorf("Pending() = %v, want %v", got, tc.wantPending)
+			}
+		})
+	}
+}
@@ -0,0 +1,246 @@
+package dkb4q
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Clock schedules functions to run after a delay. It is implemented by the
+// system clock and by fake.Clock, which allows testing code using Notifier
+// without waiting.
+type Clock interface {
+	// AfterFunc calls f in its own goroutine after d has elapsed. The
+	// returned function cancels the call, see time.Timer.Stop.
+	AfterFunc(d time.Duration, f func()) (stop func() bool)
+}
+
+type systemClock struct{}
+
+func (systemClock) AfterFunc(d time.Duration, f func()) func() bool {
+	return time.AfterFunc(d, f).Stop
+}
+
+// NotificationID identifies a notification shown by Notifier.
+type NotificationID uint64
+
+// restoreTimeout is the time restoring a key after a notification expired may
+// take.
+const restoreTimeout = 10 * time.Second
+
+// restoreRetryInterval is the time after which restoring a key is attempted
+// again, if it failed after a notification expired.
+const restoreRetryInterval = 30 * time.Second
+
+// Notifier shows notifications, e.g. a blinking Esc key when a deploy failed,
+// on top of the keyboard's regular state. Notifications expire after a TTL
+// or when they are dismissed; the key then returns to what it showed before.
+//
+// Notifications for the same key form a stack: the notification shown last
+// is visible, and removing it reveals the notification below. Once all
+// notifications of a key are gone, the key's regular state is restored. The
+// regular state is set with Notifier.SetState. Keys that have not been set
+// this way are restored to the state last committed via the underlying
+// keyboard, as reported by Keyboard.LastCommitted or Conn.LastCommitted, or
+// turned off if it is unknown.
+//
+// Notifier is safe for concurrent use.
+type Notifier struct {
+	kb    StateSetter
+	clock Clock
+
+	mu      sync.Mutex
+	lastID  NotificationID
+	regular map[Key]State
+	stacks  map[Key][]*notification
+	byID    map[NotificationID]*notification
+	err     error
+}
+
+type notification struct {
+	id    NotificationID
+	state State
+	stop  func() bool
+}
+
+// NotifierOption is an option for NewNotifier.
+type NotifierOption func(*Notifier)
+
+// NotifierClock sets the clock used to expire notifications. The default is
+// the system clock.
+func NotifierClock(c Clock) NotifierOption {
+	return func(n *Notifier) {
+		n.clock = c
+	}
+}
+
+// NewNotifier returns a new Notifier setting the state of kb.
+func NewNotifier(kb StateSetter, opts ...NotifierOption) *Notifier {
+	n := &Notifier{
+		kb:      kb,
+		clock:   systemClock{},
+		regular: make(map[Key]State),
+		stacks:  make(map[Key][]*notification),
+		byID:    make(map[NotificationID]*notification),
+	}
+	for _, opt := range opts {
+		opt(n)
+	}
+	return n
+}
+
+// SetState sets the regular state of keys, i.e. the state shown when there
+// is no notification. Keys with a notification are only updated once all
+// their notifications are gone.
+func (n *Notifier) SetState(ctx context.Context, states ...State) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	var visible []State
+	for _, s := range states {
+		n.regular[s.ID] = s
+		if len(n.stacks[s.ID]) == 0 {
+			visible = append(visible, s)
+		}
+	}
+
+	if len(visible) == 0 {
+		return nil
+	}
+	return n.kb.SetState(ctx, visible...)
+}
+
+// Notify shows s on top of all other notifications for the key s.ID. If ttl
+// is positive, the notification is removed after ttl; otherwise it is shown
+// until it is dismissed.
+func (n *Notifier) Notify(ctx context.Context, s State, ttl time.Duration) (NotificationID, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.rememberRegular(s.ID)
+
+	if err := n.kb.SetState(ctx, s); err != nil {
+		return 0, err
+	}
+
+	n.lastID++
+	nt := &notification{
+		id:    n.lastID,
+		state: s,
+	}
+	if ttl > 0 {
+		id := nt.id
+		nt.stop = n.clock.AfterFunc(ttl, func() {
+			n.expire(id)
+		})
+	}
+
+	n.stacks[s.ID] = append(n.stacks[s.ID], nt)
+	n.byID[nt.id] = nt
+
+	return nt.id, nil
+}
+
+// Dismiss removes a notification before its TTL expires. Dismissing a
+// notification that has already been removed is not an error. If restoring
+// the key fails, the notification is kept and Dismiss may be called again.
+func (n *Notifier) Dismiss(ctx context.Context, id NotificationID) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	nt, ok := n.byID[id]
+	if !ok {
+		return nil
+	}
+	if nt.stop != nil {
+		nt.stop()
+	}
+
+	return n.remove(ctx, nt)
+}
+
+// Err returns the error that occurred when restoring a key after a
+// notification expired, if any. The notification is kept in that case and
+// restoring the key is retried periodically.
+func (n *Notifier) Err() error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	return n.err
+}
+
+func (n *Notifier) expire(id NotificationID) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	nt, ok := n.byID[id]
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), restoreTimeout)
+	defer cancel()
+
+	if err := n.remove(ctx, nt); err != nil {
+		n.err = err
+		nt.stop = n.clock.AfterFunc(restoreRetryInterval, func() {
+			n.expire(id)
+		})
+	}
+}
+
+// remove removes nt from its key's stack and shows the notification below,
+// or the regular state. If that fails, nt is kept. The caller must hold n.mu.
+func (n *Notifier) remove(ctx context.Context, nt *notification) error {
+	id := nt.state.ID
+
+	var stack []*notification
+	for _, other := range n.stacks[id] {
+		if other != nt {
+			stack = append(stack, other)
+		}
+	}
+
+	// nt is visible if it is on top of the stack. Otherwise it is covered
+	// by another notification and nothing changes.
+	old := n.stacks[id]
+	if old[len(old)-1] == nt {
+		next := n.regular[id]
+		if len(stack) != 0 {
+			next = stack[len(stack)-1].state
+		}
+		if err := n.kb.SetState(ctx, next); err != nil {
+			return err
+		}
+	}
+
+	delete(n.byID, nt.id)
+	if len(stack) == 0 {
+		delete(n.stacks, id)
+	} else {
+		n.stacks[id] = stack
+	}
+	return nil
+}
+
+// rememberRegular determines the regular state of a key before its first
+// notification is shown. The caller must hold n.mu.
+func (n *Notifier) rememberRegular(id Key) {
+	if _, ok := n.regular[id]; ok {
+		return
+	}
+
+	n.regular[id] = State{ID: id, IdleEffect: SetColor, ActiveEffect: None}
+
+	type committer interface {
+		LastCommitted(id Key) (State, bool)
+	}
+	c, ok := n.kb.(committer)
+	if !ok {
+		return
+	}
+
+	if s, ok := c.LastCommitted(id); ok {
+		n.regular[id] = s
+	}
+}
@@ -0,0 +1,226 @@
+package dkb4q
+
+import (
+	"context"
+	"errors"
+	"image/color"
+	"testing"
+	"time"
+
+	"github.com/octo/das/dkb4q/fake"
+)
+
+func TestNotifier(t *testing.T) {
+	var (
+		ctx    = context.Background()
+		red    = color.NRGBA{R: 0xFF, A: 0xFF}
+		blue   = color.NRGBA{B: 0xFF, A: 0xFF}
+		yellow = color.NRGBA{R: 0xFF, G: 0xFF, A: 0xFF}
+		black  = color.NRGBA{A: 0xFF}
+	)
+
+	sim := fake.NewSimulator()
+	kb := New(sim)
+	defer kb.Close()
+
+	clock := &fake.Clock{}
+	n := NewNotifier(&kb, NotifierClock(clock))
+
+	wantColor := func(t *testing.T, id Key, want color.NRGBA) {
+		t.Helper()
+		got, ok := sim.Committed(uint8(id))
+		if !ok {
+			t.Fatalf("key %v has not been committed", id)
+		}
+		if got.IdleColor != want {
+			t.Errorf("key %v: IdleColor = %v, want %v", id, got.IdleColor, want)
+		}
+	}
+
+	if err := n.SetState(ctx, State{ID: KeyEsc, IdleEffect: SetColor, IdleColor: blue}); err != nil {
+		t.Fatal(err)
+	}
+
+	deploy, err := n.Notify(ctx, State{ID: KeyEsc, IdleEffect: Blink, IdleColor: red}, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantColor(t, KeyEsc, red)
+
+	t.Run("stack", func(t *testing.T) {
+		build, err := n.Notify(ctx, State{ID: KeyEsc, IdleEffect: SetColor, IdleColor: yellow}, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		wantColor(t, KeyEsc, yellow)
+
+		if err := n.Dismiss(ctx, build); err != nil {
+			t.Fatal(err)
+		}
+		wantColor(t, KeyEsc, red)
+	})
+
+	t.Run("regular state is not shown while notified", func(t *testing.T) {
+		if err := n.SetState(ctx, State{ID: KeyEsc, IdleEffect: SetColor, IdleColor: black}); err != nil {
+			t.Fatal(err)
+		}
+		wantColor(t, KeyEsc, red)
+	})
+
+	t.Run("expiry", func(t *testing.T) {
+		clock.Advance(59 * time.Second)
+		wantColor(t, KeyEsc, red)
+
+		clock.Advance(time.Second)
+		wantColor(t, KeyEsc, black)
+
+		if err := n.Err(); err != nil {
+			t.Errorf("Err() = %v", err)
+		}
+
+		// dismissing an expired notification is a no-op.
+		if err := n.Dismiss(ctx, deploy); err != nil {
+			t.Errorf("Dismiss(expired) = %v", err)
+		}
+	})
+
+	t.Run("covered notification expires", func(t *testing.T) {
+		if _, err := n.Notify(ctx, State{ID: KeyF1, IdleEffect: SetColor, IdleColor: red}, time.Second); err != nil {
+			t.Fatal(err)
+		}
+		top, err := n.Notify(ctx, State{ID: KeyF1, IdleEffect: SetColor, IdleColor: yellow}, time.Minute)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		commits := sim.Commits()
+		clock.Advance(time.Second)
+		wantColor(t, KeyF1, yellow)
+		if got := sim.Commits(); got != commits {
+			t.Errorf("Commits() = %d, want %d", got, commits)
+		}
+
+		if err := n.Dismiss(ctx, top); err != nil {
+			t.Fatal(err)
+		}
+		// F1 was never set via the Notifier: restored to off.
+		wantColor(t, KeyF1, black)
+	})
+}
+
+func TestNotifier_RestoreKeyboardState(t *testing.T) {
+	var (
+		ctx   = context.Background()
+		red   = color.NRGBA{R: 0xFF, A: 0xFF}
+		green = color.NRGBA{G: 0xFF, A: 0xFF}
+	)
+
+	sim := fake.NewSimulator()
+	kb := New(sim)
+	defer kb.Close()
+
+	// F2 is set directly, not via the Notifier.
+	if err := kb.SetState(ctx, State{ID: KeyF2, IdleEffect: Breathe, IdleColor: green}); err != nil {
+		t.Fatal(err)
+	}
+
+	clock := &fake.Clock{}
+	n := NewNotifier(&kb, NotifierClock(clock))
+
+	if _, err := n.Notify(ctx, State{ID: KeyF2, IdleEffect: Blink, IdleColor: red}, time.Second); err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(time.Second)
+
+	got, _ := sim.Committed(uint8(KeyF2))
+	if got.IdleEffect != uint8(Breathe) || got.IdleColor != green {
+		t.Errorf("F2 = %+v, want green breathe", got)
+	}
+}
+
+func TestNotifier_RestoreConnState(t *testing.T) {
+	var (
+		ctx   = context.Background()
+		red   = color.NRGBA{R: 0xFF, A: 0xFF}
+		green = color.NRGBA{G: 0xFF, A: 0xFF}
+	)
+
+	sim := fake.NewSimulator()
+	conn := NewConn(func() (Keyboard, error) { return New(sim), nil })
+	defer conn.Close()
+
+	// F2 is set directly, not via the Notifier.
+	if err := conn.SetState(ctx, State{ID: KeyF2, IdleEffect: Breathe, IdleColor: green}); err != nil {
+		t.Fatal(err)
+	}
+
+	clock := &fake.Clock{}
+	n := NewNotifier(conn, NotifierClock(clock))
+
+	if _, err := n.Notify(ctx, State{ID: KeyF2, IdleEffect: Blink, IdleColor: red}, time.Second); err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(time.Second)
+
+	got, _ := sim.Committed(uint8(KeyF2))
+	if got.IdleEffect != uint8(Breathe) || got.IdleColor != green {
+		t.Errorf("F2 = %+v, want green breathe", got)
+	}
+}
+
+// failingSetter fails SetState while err is set.
+type failingSetter struct {
+	kb  StateSetter
+	err error
+}
+
+func (f *failingSetter) SetState(ctx context.Context, states ...State) error {
+	if f.err != nil {
+		return f.err
+	}
+	return f.kb.SetState(ctx, states...)
+}
+
+func TestNotifier_RestoreFails(t *testing.T) {
+	var (
+		ctx     = context.Background()
+		red     = color.NRGBA{R: 0xFF, A: 0xFF}
+		green   = color.NRGBA{G: 0xFF, A: 0xFF}
+		errFail = errors.New("keyboard unplugged")
+	)
+
+	sim := fake.NewSimulator()
+	kb := New(sim)
+	defer kb.Close()
+
+	fs := &failingSetter{kb: &kb}
+	clock := &fake.Clock{}
+	n := NewNotifier(fs, NotifierClock(clock))
+
+	if err := n.SetState(ctx, State{ID: KeyEsc, IdleEffect: SetColor, IdleColor: green}); err != nil {
+		t.Fatal(err)
+	}
+	id, err := n.Notify(ctx, State{ID: KeyEsc, IdleEffect: SetColor, IdleColor: red}, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fs.err = errFail
+	clock.Advance(time.Second)
+	if err := n.Err(); !errors.Is(err, errFail) {
+		t.Errorf("Err() = %v, want %v", err, errFail)
+	}
+	if err := n.Dismiss(ctx, id); !errors.Is(err, errFail) {
+		t.Errorf("Dismiss() = %v, want %v", err, errFail)
+	}
+
+	// The notification is kept, so dismissing it again restores the key.
+	fs.err = nil
+	if err := n.Dismiss(ctx, id); err != nil {
+		t.Fatalf("Dismiss() = %v", err)
+	}
+	got, _ := sim.Committed(uint8(KeyEsc))
+	if got.IdleColor != green {
+		t.Errorf("Esc: IdleColor = %v, want %v", got.IdleColor, green)
+	}
+}
@@ -0,0 +1,76 @@
+package dkb4q
+
+import "fmt"
+
+const (
+	// commandReport is the report type of commands sent to the keyboard.
+	commandReport = 0xEA
+	// responseReport is the report type of the keyboard's responses.
+	responseReport = 0xED
+	// ledCommand is the first byte of all commands changing LED states.
+	ledCommand = 0x78
+	// statusOK is the status of a successful response.
+	statusOK = 0x00
+)
+
+// Response is a decoded response report, e.g. "ED 03 78 00 96".
+type Response struct {
+	// Type is the report type. Responses use 0xED.
+	Type byte
+	// Command is the first byte of the command this is a response to.
+	Command byte
+	// Status is zero if the command was successful.
+	Status byte
+	// Data holds any additional payload, excluding the parity byte.
+	Data []byte
+}
+
+// OK returns true if the response is a successful ACK of an LED command.
+func (r Response) OK() bool {
+	return r.Type == responseReport && r.Command == ledCommand && r.Status == statusOK
+}
+
+func (r Response) String() string {
+	return fmt.Sprintf("Response{Type: %#x, Command: %#x, Status: %#x, Data: %#v}", r.Type, r.Command, r.Status, r.Data)
+}
+
+// parseResponse decodes a report as returned by getReports. The report's
+// length and parity must have been validated already.
+func parseResponse(report []byte) (Response, error) {
+	// type, length, command, status, parity
+	if len(report) < 5 {
+		return Response{}, fmt.Errorf("response %#v too short: got %d bytes, want at least 5", report, len(report))
+	}
+
+	return Response{
+		Type:    report[0],
+		Command: report[2],
+		Status:  report[3],
+		Data:    report[4 : len(report)-1],
+	}, nil
+}
+
+// ResponseError is returned when the keyboard does not acknowledge a command
+// with a success status.
+type ResponseError struct {
+	Response Response
+}
+
+func (e *ResponseError) Error() string {
+	return fmt.Sprintf("command %#x not acknowledged: type %#x, status %#x", e.Response.Command, e.Response.Type, e.Response.Status)
+}
+
+// checkResponses parses all reports and returns a *ResponseError for the
+// first report that is not a successful ACK.
+func checkResponses(reports [][]byte) error {
+	for _, report := range reports {
+		res, err := parseResponse(report)
+		if err != nil {
+			return err
+		}
+		if !res.OK() {
+			return &ResponseError{Response: res}
+		}
+	}
+	return nil
+}
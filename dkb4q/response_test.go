@@ -0,0 +1,78 @@
+package dkb4q
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseResponse(t *testing.T) {
+	cases := []struct {
+		report  []byte
+		want    Response
+		wantOK  bool
+		wantErr bool
+	}{
+		{
+			report: []byte{0xED, 0x03, 0x78, 0x00, 0x96},
+			want:   Response{Type: 0xED, Command: 0x78, Status: 0x00, Data: []byte{}},
+			wantOK: true,
+		},
+		{
+			report: []byte{0xED, 0x03, 0x78, 0x01, 0x97},
+			want:   Response{Type: 0xED, Command: 0x78, Status: 0x01, Data: []byte{}},
+		},
+		{
+			report: []byte{0xED, 0x05, 0x78, 0x00, 0x01, 0x02, 0x93},
+			want:   Response{Type: 0xED, Command: 0x78, Status: 0x00, Data: []byte{0x01, 0x02}},
+			wantOK: true,
+		},
+		{
+			report: []byte{0xEE, 0x03, 0x78, 0x00, 0x95},
+			want:   Response{Type: 0xEE, Command: 0x78, Status: 0x00, Data: []byte{}},
+		},
+		{
+			report:  []byte{0xED, 0x02, 0x78, 0x97},
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range cases {
+		got, err := parseResponse(tc.report)
+		if gotErr := err != nil; gotErr != tc.wantErr {
+			t.Errorf("parseResponse(%#v) = %v, want error %v", tc.report, err, tc.wantErr)
+		}
+		if tc.wantErr {
+			continue
+		}
+
+		if diff := cmp.Diff(tc.want, got); diff != "" {
+			t.Errorf("parseResponse(%#v) differs (+got/-want):\n%s", tc.report, diff)
+		}
+		if got.OK() != tc.wantOK {
+			t.Errorf("parseResponse(%#v).OK() = %v, want %v", tc.report, got.OK(), tc.wantOK)
+		}
+	}
+}
+
+func TestCheckResponses(t *testing.T) {
+	ack := []byte{0xED, 0x03, 0x78, 0x00, 0x96}
+	nack := []byte{0xED, 0x03, 0x78, 0x01, 0x97}
+
+	if err := checkResponses([][]byte{ack, ack}); err != nil {
+		t.Errorf("checkResponses(ACK, ACK) = %v, want nil", err)
+	}
+	if err := checkResponses(nil); err != nil {
+		t.Errorf("checkResponses(nil) = %v, want nil", err)
+	}
+
+	err := checkResponses([][]byte{ack, nack})
+	var resErr *ResponseError
+	if !errors.As(err, &resErr) {
+		t.Fatalf("checkResponses(ACK, NACK) = %v, want *ResponseError", err)
+	}
+	if resErr.Response.Status != 0x01 {
+		t.Errorf("ResponseError.Response.Status = %#x, want 0x01", resErr.Response.Status)
+	}
+}
@@ -11,7 +11,7 @@ import (
 // State represents the (desired) state of one key. "Idle" refers to the
 // key's normal state, "active" to the keys state after is has been pressed.
 type State struct {
-	ID           uint8
+	ID           Key
 	IdleEffect   IdleEffect
 	IdleColor    color.NRGBA
 	ActiveEffect ActiveEffect
@@ -21,6 +21,37 @@ type State struct {
 // SetState sets the state of one or more LEDs / keys. Passing many states in
 // one call is more efficient than calling SetState repeatedly.
 func (kb *Keyboard) SetState(ctx context.Context, states ...State) error {
+	if err := kb.lock(); err != nil {
+		return err
+	}
+	defer kb.mu.Unlock()
+
+	return kb.setStateLocked(ctx, states)
+}
+
+// setStateLocked implements SetState. The caller must hold kb.mu.
+func (kb *Keyboard) setStateLocked(ctx context.Context, states []State) error {
+	if kb.dev == nil {
+		return errNotOpen
+	}
+
+	var err error
+	if kb.pipelined {
+		err = kb.setStatePipelined(ctx, states)
+	} else {
+		err = kb.setState(ctx, states)
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, s := range states {
+		kb.committed[s.ID] = s
+	}
+	return nil
+}
+
+func (kb *Keyboard) setState(ctx context.Context, states []State) error {
 	for _, s := range states {
 		if err := kb.stageState(ctx, s); err != nil {
 			return err
@@ -30,8 +61,69 @@ func (kb *Keyboard) SetState(ctx context.Context, states ...State) error {
 	return kb.commitState(ctx)
 }
 
+// Pipelined enables pipelining of SetState: all messages are sent without
+// waiting for the keyboard's response to each key. The responses are then
+// read and validated in bulk. This reduces the number of round trips from
+// 2*len(states)+1 to one.
+func Pipelined() Option {
+	return func(kb *Keyboard) {
+		kb.pipelined = true
+	}
+}
+
+// stateMessages returns the messages staging s: msg0 selects the key, msg1
+// sets the idle state and msg2 sets the active state. The keyboard responds
+// to msg0 and msg2.
+func stateMessages(s State) (msg0, msg1, msg2 []byte) {
+	msg0 = encodeReport(0xEA, []byte{0x78, 0x03, byte(s.ID), 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00})
+	msg1 = encodeReport(0xEA, []byte{0x78, 0x08, byte(s.ID), byte(s.IdleEffect),
+		s.IdleColor.R, s.IdleColor.G, s.IdleColor.B})
+	msg2 = encodeReport(0xEA, []byte{0x78, 0x04, byte(s.ID), s.ActiveEffect.id,
+		s.ActiveColor.R, s.ActiveColor.G, s.ActiveColor.B,
+		s.ActiveEffect.arg0,
+		s.ActiveEffect.arg1,
+		s.ActiveEffect.arg2})
+	return msg0, msg1, msg2
+}
+
+// commitMessage is the message committing all staged states.
+var commitMessage = encodeReport(0xEA, []byte{0x78, 0x0A})
+
+func (kb *Keyboard) setStatePipelined(ctx context.Context, states []State) error {
+	for _, s := range states {
+		msg0, msg1, msg2 := stateMessages(s)
+		for _, msg := range [][]byte{msg0, msg1, msg2} {
+			if err := kb.setReport(ctx, msg); err != nil {
+				return fmt.Errorf("setReport(%#v) = %w", msg, err)
+			}
+		}
+	}
+
+	if err := kb.setReport(ctx, commitMessage); err != nil {
+		return err
+	}
+
+	// two responses per key and one for the commit.
+	want := 2*len(states) + 1
+
+	var res [][]byte
+	for len(res) < want {
+		r, err := kb.getReports(ctx)
+		if err != nil {
+			return fmt.Errorf("reading response %d of %d: %w", len(res)+1, want, err)
+		}
+		res = append(res, r...)
+	}
+	kb.trace().Response(res)
+
+	if err := checkResponses(res); err != nil {
+		return fmt.Errorf("setting state: %w", err)
+	}
+	return nil
+}
+
 func (kb *Keyboard) stageState(ctx context.Context, s State) error {
-	msg0 := encodeReport(0xEA, []byte{0x78, 0x03, s.ID, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00})
+	msg0, msg1, msg2 := stateMessages(s)
 	if err := kb.setReport(ctx, msg0); err != nil {
 		return fmt.Errorf("setReport(msg0 = %#v) = %w", msg0, err)
 	}
@@ -41,20 +133,15 @@ func (kb *Keyboard) stageState(ctx context.Context, s State) error {
 		return err
 	}
 	// should return "ED 03 78 00 96"
-	fmt.Printf("response 0 = %#v\n", res0)
+	kb.trace().Response(res0)
+	if err := checkResponses(res0); err != nil {
+		return fmt.Errorf("staging key %v: %w", s.ID, err)
+	}
 
-	msg1 := encodeReport(0xEA, []byte{0x78, 0x08, s.ID, byte(s.IdleEffect),
-		s.IdleColor.R, s.IdleColor.G, s.IdleColor.B})
 	if err := kb.setReport(ctx, msg1); err != nil {
 		return fmt.Errorf("setReport(msg1 = %#v) = %w", msg1, err)
 	}
 
-	msg2 := []byte{0x78, 0x04, s.ID, s.ActiveEffect.id,
-		s.ActiveColor.R, s.ActiveColor.G, s.ActiveColor.B,
-		s.ActiveEffect.arg0,
-		s.ActiveEffect.arg1,
-		s.ActiveEffect.arg2}
-	msg2 = encodeReport(0xEA, msg2)
 	if err := kb.setReport(ctx, msg2); err != nil {
 		return fmt.Errorf("setReport(msg2 = %#v) = %w", msg2, err)
 	}
@@ -64,14 +151,16 @@ func (kb *Keyboard) stageState(ctx context.Context, s State) error {
 		return err
 	}
 	// should return "ED 03 78 00 96"
-	fmt.Printf("response 1 = %#v\n", res1)
+	kb.trace().Response(res1)
+	if err := checkResponses(res1); err != nil {
+		return fmt.Errorf("staging key %v: %w", s.ID, err)
+	}
 
 	return nil
 }
 
 func (kb *Keyboard) commitState(ctx context.Context) error {
-	msg3 := encodeReport(0xEA, []byte{0x78, 0x0A})
-	if err := kb.setReport(ctx, msg3); err != nil {
+	if err := kb.setReport(ctx, commitMessage); err != nil {
 		return err
 	}
 
@@ -80,7 +169,10 @@ func (kb *Keyboard) commitState(ctx context.Context) error {
 		return err
 	}
 	// should return "ED 03 78 00 96"
-	fmt.Printf("response 2 = %#v\n", res2)
+	kb.trace().Response(res2)
+	if err := checkResponses(res2); err != nil {
+		return fmt.Errorf("committing state: %w", err)
+	}
 
 	return nil
 }
@@ -113,11 +205,14 @@ var None = ActiveEffect{}
 // support.
 type ActiveEffectOption func(*ActiveEffect)
 
+// setColorActiveID is the ID of the SetColorActive effect.
+const setColorActiveID = 0x1E
+
 // SetColorActive lights the key in a single color. After some time (default:
 // 1.9 seconds) the key reverts to its idle state.
 func SetColorActive(opts ...ActiveEffectOption) ActiveEffect {
 	ae := ActiveEffect{
-		id:   0x1E,
+		id:   setColorActiveID,
 		arg0: 0x07,
 		arg1: 0xD0,
 	}
@@ -169,7 +264,7 @@ func BreatheActive(opts ...ActiveEffectOption) ActiveEffect {
 // reverts to the idle state.
 func EffectDuration(d time.Duration) ActiveEffectOption {
 	return func(ae *ActiveEffect) {
-		if ae.id != 0x1E {
+		if ae.id != setColorActiveID {
 			return
 		}
 		const precision = 270 * time.Millisecond
@@ -2,10 +2,12 @@ package dkb4q
 
 import (
 	"context"
+	"fmt"
 	"image/color"
 	"testing"
 	"time"
 
+	"github.com/google/go-cmp/cmp"
 	"github.com/octo/das/dkb4q/fake"
 )
 
@@ -94,6 +96,25 @@ func TestKeyboard_State(t *testing.T) {
 				{0xED, 0x03, 0x78, 0x00, 0x96, 0, 0, 0}, // msg 9
 			},
 		},
+		{
+			title: "NACK",
+			states: []State{
+				{
+					ID:           0x05,
+					IdleEffect:   SetColor,
+					IdleColor:    color.NRGBA{R: 0xFB, G: 0x02, B: 0x03},
+					ActiveEffect: None,
+				},
+			},
+			wantSetReport: [][]byte{
+				{1, 0xEA, 0x0B, 0x78, 0x03, 0x05, 0x00, 0x00}, // msg 0
+				{1, 0x00, 0x00, 0x00, 0x00, 0x00, 0x9F, 0},    // msg 1
+			},
+			wantGetReport: [][]byte{
+				{0xED, 0x03, 0x78, 0x01, 0x97, 0, 0, 0}, // msg 2
+			},
+			wantErr: true,
+		},
 	}
 
 	for _, tc := range cases {
@@ -116,9 +137,7 @@ func TestKeyboard_State(t *testing.T) {
 				})
 			}
 
-			kb := &Keyboard{
-				dev: &hid,
-			}
+			kb := New(&hid)
 			defer kb.Close()
 
 			err := kb.SetState(ctx, tc.states...)
@@ -131,3 +150,156 @@ func TestKeyboard_State(t *testing.T) {
 		})
 	}
 }
+
+func TestKeyboard_SetStatePipelined(t *testing.T) {
+	states := []State{
+		{ID: KeyEsc, IdleEffect: SetColor, IdleColor: color.NRGBA{R: 0xFF}},
+		{ID: KeyF1, IdleEffect: Breathe, IdleColor: color.NRGBA{G: 0xFF}, ActiveEffect: BlinkActive(), ActiveColor: color.NRGBA{B: 0xFF}},
+		{ID: KeyF2, IdleEffect: SetColor, IdleColor: color.NRGBA{B: 0xFF}, ActiveEffect: SetColorActive()},
+	}
+
+	t.Run("success", func(t *testing.T) {
+		var (
+			ctx = context.Background()
+			hid fake.HID
+		)
+		expectSetState(&hid, states...)
+
+		dev := &countingDevice{Device: &hid}
+		kb := New(dev, Pipelined())
+		defer kb.Close()
+
+		if err := kb.SetState(ctx, states...); err != nil {
+			t.Fatalf("Keyboard.SetState() = %v", err)
+		}
+		if dev.roundTrips != 1 {
+			t.Errorf("Keyboard.SetState() took %d round trips, want 1", dev.roundTrips)
+		}
+	})
+
+	t.Run("NACK", func(t *testing.T) {
+		var (
+			ctx = context.Background()
+			hid fake.HID
+		)
+		expectSetState(&hid, states...)
+		// Reject the second key.
+		hid.WantGetReport[2] = fake.Report{ID: 1, Data: []byte{0xED, 0x03, 0x78, 0x01, 0x97, 0, 0, 0}}
+
+		kb := New(&hid, Pipelined())
+		defer kb.Close()
+
+		if err := kb.SetState(ctx, states...); err == nil {
+			t.Errorf("Keyboard.SetState() = %v, want error", err)
+		}
+	})
+}
+
+// countingDevice counts the round trips, i.e. the number of times the host
+// waits for a response after sending data.
+type countingDevice struct {
+	Device
+	sending    bool
+	roundTrips int
+}
+
+func (d *countingDevice) SetReport(id int, data []byte) error {
+	d.sending = true
+	return d.Device.SetReport(id, data)
+}
+
+func (d *countingDevice) GetReport(id int) ([]byte, error) {
+	if d.sending {
+		d.roundTrips++
+		d.sending = false
+	}
+	return d.Device.GetReport(id)
+}
+
+func BenchmarkKeyboard_SetState(b *testing.B) {
+	var states []State
+	for id := 0; id <= MaxID; id++ {
+		states = append(states, State{
+			ID:         Key(id),
+			IdleEffect: SetColor,
+			IdleColor:  color.NRGBA{R: uint8(id)},
+		})
+	}
+
+	for _, pipelined := range []bool{false, true} {
+		b.Run(fmt.Sprintf("pipelined=%v", pipelined), func(b *testing.B) {
+			var (
+				ctx = context.Background()
+				hid fake.HID
+				dev = &countingDevice{Device: &hid}
+			)
+
+			var opts []Option
+			if pipelined {
+				opts = append(opts, Pipelined())
+			}
+			kb := New(dev, opts...)
+			defer kb.Close()
+
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				expectSetState(&hid, states...)
+				b.StartTimer()
+
+				if err := kb.SetState(ctx, states...); err != nil {
+					b.Fatal(err)
+				}
+			}
+
+			b.ReportMetric(float64(dev.roundTrips)/float64(b.N), "roundtrips/op")
+		})
+	}
+}
+
+func TestKeyboard_SetStateSimulator(t *testing.T) {
+	var (
+		red  = color.NRGBA{R: 0xFF, A: 0xFF}
+		blue = color.NRGBA{B: 0xFF, A: 0xFF}
+	)
+
+	for _, pipelined := range []bool{false, true} {
+		t.Run(fmt.Sprintf("pipelined=%v", pipelined), func(t *testing.T) {
+			ctx := context.Background()
+			sim := fake.NewSimulator()
+
+			var opts []Option
+			if pipelined {
+				opts = append(opts, Pipelined())
+			}
+			kb := New(sim, opts...)
+			defer kb.Close()
+
+			err := kb.SetState(ctx,
+				State{ID: KeyF1, IdleEffect: SetColor, IdleColor: red},
+				State{ID: KeyF2, IdleEffect: Breathe, IdleColor: blue, ActiveEffect: BlinkActive(CycleCount(2)), ActiveColor: red},
+			)
+			if err != nil {
+				t.Fatalf("Keyboard.SetState() = %v", err)
+			}
+
+			want := map[Key]fake.KeyState{
+				KeyF1: {IdleEffect: uint8(SetColor), IdleColor: red, ActiveColor: color.NRGBA{A: 0xFF}},
+				KeyF2: {IdleEffect: uint8(Breathe), IdleColor: blue, ActiveEffect: uint8(Blink), ActiveColor: red, ActiveArgs: [3]byte{0x01, 0xF4, 0x02}},
+			}
+			for id, want := range want {
+				got, ok := sim.Committed(uint8(id))
+				if !ok {
+					t.Errorf("key %v has not been committed", id)
+					continue
+				}
+				if diff := cmp.Diff(want, got); diff != "" {
+					t.Errorf("key %v differs (+got/-want):\n%s", id, diff)
+				}
+			}
+
+			if got := sim.Commits(); got != 1 {
+				t.Errorf("Commits() = %d, want 1", got)
+			}
+		})
+	}
+}
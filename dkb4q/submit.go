@@ -0,0 +1,129 @@
+package dkb4q
+
+import (
+	"context"
+	"sort"
+	"sync"
+)
+
+// Submitter sends states to the keyboard in the background. It is intended
+// for high-frequency producers, such as animations: if states are submitted
+// faster than the keyboard can apply them, only the latest state of each key
+// is sent and intermediate states are dropped.
+//
+// Submitter is safe for concurrent use.
+type Submitter struct {
+	kb     StateSetter
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu      sync.Mutex
+	pending map[Key]State
+	err     error
+
+	wake      chan struct{}
+	closing   chan struct{}
+	closeOnce sync.Once
+	done      chan struct{}
+}
+
+// NewSubmitter starts a Submitter sending states to kb. The Submitter stops
+// when ctx is cancelled or Close is called.
+func NewSubmitter(ctx context.Context, kb StateSetter) *Submitter {
+	ctx, cancel := context.WithCancel(ctx)
+
+	s := &Submitter{
+		kb:      kb,
+		ctx:     ctx,
+		cancel:  cancel,
+		pending: make(map[Key]State),
+		wake:    make(chan struct{}, 1),
+		closing: make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	go s.run()
+
+	return s
+}
+
+// Submit queues states to be sent to the keyboard and returns immediately. A
+// state replaces any queued state for the same key that has not been sent yet.
+func (s *Submitter) Submit(states ...State) {
+	s.mu.Lock()
+	for _, st := range states {
+		s.pending[st.ID] = st
+	}
+	s.mu.Unlock()
+
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Err returns the error of the last attempt to send states, if it failed.
+// States that could not be sent are retried with the next Submit, unless
+// they have been replaced in the meantime.
+func (s *Submitter) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// Close sends all queued states and stops the Submitter. It returns the error
+// of the last attempt to send states. Close does not close kb. Calling Close
+// more than once is safe.
+func (s *Submitter) Close() error {
+	s.closeOnce.Do(func() { close(s.closing) })
+	<-s.done
+	s.cancel()
+
+	return s.Err()
+}
+
+func (s *Submitter) run() {
+	defer close(s.done)
+
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-s.closing:
+			s.flush()
+			return
+		case <-s.wake:
+			s.flush()
+		}
+	}
+}
+
+func (s *Submitter) flush() {
+	s.mu.Lock()
+	states := make([]State, 0, len(s.pending))
+	for _, st := range s.pending {
+		states = append(states, st)
+	}
+	s.pending = make(map[Key]State)
+	s.mu.Unlock()
+
+	if len(states) == 0 {
+		return
+	}
+	sort.Slice(states, func(i, j int) bool { return states[i].ID < states[j].ID })
+
+	err := s.kb.SetState(s.ctx, states...)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.err = err
+	if err == nil {
+		return
+	}
+	// Re-queue the states that have not been replaced.
+	for _, st := range states {
+		if _, ok := s.pending[st.ID]; !ok {
+			s.pending[st.ID] = st
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package dkb4q
+
+import (
+	"context"
+	"image/color"
+	"testing"
+)
+
+// ackDevice accepts all reports and acknowledges every message.
+type ackDevice struct {
+	setReports [][]byte
+}
+
+func (d *ackDevice) Close() {}
+
+func (d *ackDevice) SetReport(_ int, data []byte) error {
+	d.setReports = append(d.setReports, append([]byte{}, data...))
+	return nil
+}
+
+func (d *ackDevice) GetReport(int) ([]byte, error) {
+	return []byte{0xED, 0x03, 0x78, 0x00, 0x96, 0, 0, 0}, nil
+}
+
+// idleRed returns the red channel of the last idle color sent for id.
+func (d *ackDevice) idleRed(id Key) (uint8, bool) {
+	for i := len(d.setReports) - 1; i >= 0; i-- {
+		r := d.setReports[i]
+		// first report of msg1: 01 EA 08 78 08 <id> <effect> <R>
+		if r[1] == 0xEA && r[2] == 0x08 && r[3] == 0x78 && r[4] == 0x08 && Key(r[5]) == id {
+			return r[7], true
+		}
+	}
+	return 0, false
+}
+
+func TestSubmitter(t *testing.T) {
+	var (
+		ctx = context.Background()
+		dev ackDevice
+	)
+
+	kb := New(&dev)
+	defer kb.Close()
+
+	s := NewSubmitter(ctx, &kb)
+	const n = 100
+	for i := 1; i <= n; i++ {
+		s.Submit(
+			State{ID: KeyF1, IdleEffect: SetColor, IdleColor: color.NRGBA{R: uint8(i)}},
+			State{ID: KeyF2, IdleEffect: SetColor, IdleColor: color.NRGBA{R: uint8(n - i)}},
+		)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("Submitter.Close() = %v", err)
+	}
+	if err := s.Close(); err != nil {
+		t.Errorf("second Submitter.Close() = %v", err)
+	}
+
+	for id, want := range map[Key]uint8{KeyF1: n, KeyF2: 0} {
+		got, ok := dev.idleRed(id)
+		if !ok || got != want {
+			t.Errorf("last color of %v = %d, %v; want %d, true", id, got, ok, want)
+		}
+	}
+
+	// Each SetState sends six reports per key and one for the commit.
+	if got, max := len(dev.setReports), n*(2*6+1); got > max {
+		t.Errorf("len(setReports) = %d, want at most %d", got, max)
+	}
+}
@@ -0,0 +1,85 @@
+package dkb4q
+
+import (
+	"fmt"
+	"io"
+)
+
+// Tracer receives the low-level communication with the keyboard. It is
+// intended for debugging; by default, no tracing happens.
+type Tracer interface {
+	// Device is called by Open for every device with the "Das Keyboard"
+	// vendor ID, before deciding whether to use it.
+	Device(info DeviceInfo)
+	// SetReport is called after each report sent to the device.
+	SetReport(reportID int, data []byte, err error)
+	// GetReport is called after each report read from the device.
+	GetReport(reportID int, data []byte, err error)
+	// Response is called with the reports decoded from the keyboard's
+	// response to a command.
+	Response(reports [][]byte)
+	// Discarded is called with bytes received from the keyboard that are
+	// not part of a valid report.
+	Discarded(data []byte)
+}
+
+// Tracing sets a Tracer that receives all communication with the keyboard.
+func Tracing(t Tracer) Option {
+	return func(kb *Keyboard) {
+		kb.tracer = t
+	}
+}
+
+// WriterTracer returns a Tracer that prints all communication with the
+// keyboard to w.
+func WriterTracer(w io.Writer) Tracer {
+	return writerTracer{w: w}
+}
+
+type writerTracer struct {
+	w io.Writer
+}
+
+func (t writerTracer) Device(info DeviceInfo) {
+	fmt.Fprintln(t.w, "device =", info)
+}
+
+func (t writerTracer) SetReport(reportID int, data []byte, err error) {
+	if err != nil {
+		fmt.Fprintf(t.w, "-> SetReport(%d, %#v) = %v\n", reportID, data, err)
+		return
+	}
+	fmt.Fprintf(t.w, "-> SetReport(%d, %#v)\n", reportID, data)
+}
+
+func (t writerTracer) GetReport(reportID int, data []byte, err error) {
+	if err != nil {
+		fmt.Fprintf(t.w, "<- GetReport(%d) = %v\n", reportID, err)
+		return
+	}
+	fmt.Fprintf(t.w, "<- GetReport(%d) = %#v\n", reportID, data)
+}
+
+func (t writerTracer) Response(reports [][]byte) {
+	fmt.Fprintf(t.w, "response = %#v\n", reports)
+}
+
+func (t writerTracer) Discarded(data []byte) {
+	fmt.Fprintf(t.w, "discarded = %#v\n", data)
+}
+
+// nopTracer is used when no Tracer has been configured.
+type nopTracer struct{}
+
+func (nopTracer) Device(DeviceInfo)            {}
+func (nopTracer) SetReport(int, []byte, error) {}
+func (nopTracer) GetReport(int, []byte, error) {}
+func (nopTracer) Response([][]byte)            {}
+func (nopTracer) Discarded([]byte)             {}
+
+func (kb *Keyboard) trace() Tracer {
+	if kb.tracer == nil {
+		return nopTracer{}
+	}
+	return kb.tracer
+}
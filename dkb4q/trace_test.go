@@ -0,0 +1,45 @@
+package dkb4q
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/octo/das/dkb4q/fake"
+)
+
+func TestWriterTracer(t *testing.T) {
+	var (
+		ctx = context.Background()
+		hid = fake.HID{
+			WantSetReport: []fake.Report{
+				{ID: 1, Data: []byte{1, 0xEA, 0x03, 0x78, 0x0A, 0x9B, 0, 0}},
+			},
+			WantGetReport: []fake.Report{
+				{ID: 1, Data: []byte{0, 0, 0, 0, 0, 0, 0, 0}},
+				{ID: 1, Data: []byte{0xED, 0x03, 0x78, 0x00, 0x96, 0, 0, 0}},
+			},
+		}
+		buf bytes.Buffer
+	)
+
+	kb := New(&hid, Tracing(WriterTracer(&buf)))
+	defer kb.Close()
+
+	if err := kb.commitState(ctx); err != nil {
+		t.Fatalf("commitState() = %v", err)
+	}
+
+	want := []string{
+		"-> SetReport(1, []byte{0x1, 0xea, 0x3, 0x78, 0xa, 0x9b, 0x0, 0x0})",
+		"<- GetReport(1) = no report available",
+		"<- GetReport(1) = []byte{0xed, 0x3, 0x78, 0x0, 0x96, 0x0, 0x0, 0x0}",
+		"response = [][]uint8{[]uint8{0xed, 0x3, 0x78, 0x0, 0x96}}",
+	}
+	got := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("trace output differs (+got/-want):\n%s", diff)
+	}
+}
@@ -5,62 +5,84 @@ package das
 
 import (
 	"bytes"
+	"context"
 	"encoding/binary"
 	"errors"
 	"fmt"
 	"image/color"
 
-	"github.com/zserge/hid"
+	"github.com/octo/das/internal/usb"
+	"github.com/octo/retry"
 )
 
-// ErrNotFound is returned by Open if no matching device is found.
-var ErrNotFound = errors.New("no Das Keyboard device found")
+var (
+	// ErrNotFound is returned by Open if no matching device is found.
+	ErrNotFound = usb.ErrNotFound
+	// ErrNotOpen is returned when using a Keyboard that has not been opened
+	// or has already been closed.
+	ErrNotOpen = errors.New("connection to keyboard not open")
+)
+
+// maxSetReportAttempts is the number of times a report is sent before
+// giving up.
+const maxSetReportAttempts = 5
+
+// Device is the HID device used to talk to the keyboard. It is implemented
+// by hid.Device and by fake.HID, which allows testing code using Keyboard
+// without the hardware.
+type Device interface {
+	Close()
+	SetReport(int, []byte) error
+}
 
 // Keyboard represents the connection to a keyboard.
 type Keyboard struct {
-	dev hid.Device
+	dev Device
+}
+
+// New returns a Keyboard talking to dev. Unlike Open, New does not send the
+// initialization sequence. Use Open to connect to a keyboard attached via USB.
+//
+// The connection to the keyboard should be closed with Close(), which closes
+// dev.
+func New(dev Device) Keyboard {
+	return Keyboard{
+		dev: dev,
+	}
 }
 
 // Open scans USB devices for a "Das Keyboard" by looking for the vendor ID
 // 0x24F0. It returns a Keyboard talking to the first device successfully
-// opened. If no device could be opened, the last error is returned, or
-// ErrNotFound if no matching device was found.
+// opened. If no device could be opened, an error is returned for which
+// errors.Is(err, ErrNotFound) is true.
+//
+// Sending the initialization sequence is aborted when ctx is cancelled.
 //
 // The connection to the keyboard should be closed with Close().
-func Open() (Keyboard, error) {
-	const vendorID = 0x24F0
-
-	var (
-		device  hid.Device
-		lastErr error
-	)
-	hid.UsbWalk(func(dev hid.Device) {
-		if device != nil || dev.Info().Vendor != vendorID {
-			return
-		}
+func Open(ctx context.Context) (Keyboard, error) {
+	return OpenWith(ctx, nil)
+}
 
-		if err := dev.Open(); err != nil {
-			lastErr = err
-			return
+// OpenWith is like Open, but only considers devices accepted by sel. A nil
+// Selector accepts all devices.
+//
+// If no device could be opened, the returned error is an *OpenError listing
+// the skipped devices.
+func OpenWith(ctx context.Context, sel Selector) (Keyboard, error) {
+	dev, _, err := usb.Open(func(info DeviceInfo) error {
+		if sel != nil && !sel(info) {
+			return errNotSelected
 		}
-
-		device = dev
-		return
+		return nil
 	})
-
-	if device == nil {
-		if lastErr != nil {
-			return Keyboard{}, lastErr
-		}
-		return Keyboard{}, fmt.Errorf("no DasKeyboard device found")
-	}
-
-	kb := Keyboard{
-		dev: device,
+	if err != nil {
+		return Keyboard{}, err
 	}
 
-	if err := kb.initialize(); err != nil {
-		return Keyboard{}, err
+	kb := New(dev)
+	if err := kb.initialize(ctx); err != nil {
+		kb.Close()
+		return Keyboard{}, fmt.Errorf("initializing keyboard: %w", err)
 	}
 
 	return kb, nil
@@ -73,7 +95,7 @@ func (kb *Keyboard) Close() error {
 	}()
 
 	if kb.dev == nil {
-		return fmt.Errorf("connection to keyboard not open")
+		return ErrNotOpen
 	}
 
 	kb.dev.Close()
@@ -93,42 +115,44 @@ var initPacket = []byte{
 }
 
 // initialize initializes the keyboard by sending a magic byte sequence.
-func (kb Keyboard) initialize() error {
-	const reportID = 0
-	return kb.dev.SetReport(reportID, initPacket)
+func (kb Keyboard) initialize(ctx context.Context) error {
+	return kb.setReport(ctx, initPacket)
 }
 
-// KeyColor sets the color of a single key, identified by key ID, aka. LED ID.
-// c's alpha channel (c.A) is ignored.
-//
-// TODO(octo): read KeyInfo how key IDs / LED IDs are determined.
-func (kb Keyboard) KeyColor(c color.NRGBA, ledIDs ...uint8) error {
-	s := newKeyState()
-	s.ledIDs = ledIDs
-
-	// TODO(octo): this mapping is not true for all keys.
-	s.colors[0].toValue = c.R
-	s.colors[1].toValue = c.G
-	s.colors[2].toValue = c.B
-
-	pkgs, err := s.marshalPackets()
-	if err != nil {
-		return err
+// setReport sends one report to the keyboard, retrying failed attempts until
+// maxSetReportAttempts is reached or ctx is cancelled.
+func (kb Keyboard) setReport(ctx context.Context, data []byte) error {
+	if kb.dev == nil {
+		return ErrNotOpen
 	}
 
-	for _, pkg := range pkgs {
-		const reportID = 0
-		if err := kb.dev.SetReport(reportID, pkg); err != nil {
-			return err
+	const reportID = 0
+	attempts := 0
+	return retry.Do(ctx, func(_ context.Context) error {
+		err := kb.dev.SetReport(reportID, data)
+		if err == nil {
+			return nil
 		}
-	}
 
-	return nil
+		// Give up eventually, e.g. if the device has been unplugged.
+		attempts++
+		if attempts >= maxSetReportAttempts {
+			return retry.Abort(err)
+		}
+		return err
+	})
+}
+
+// KeyColor sets the color of one or more keys, identified by key ID, aka. LED
+// ID. c's alpha channel (c.A) is ignored. If ctx is cancelled, KeyColor
+// returns without sending the remaining packets.
+//
+// TODO(octo): read KeyInfo how key IDs / LED IDs are determined.
+func (kb Keyboard) KeyColor(ctx context.Context, c color.NRGBA, ledIDs ...uint8) error {
+	return kb.KeyEffect(ctx, Static(), c, ledIDs...)
 }
 
 type keyState struct {
-	// some keys, such as the space bar, right shift, … map to more than one LED.
-	ledIDs []uint8
 	colors [3]struct { // 0 = red, 1 = green, 2 = blue
 		channelID uint8
 		toValue   uint8
@@ -166,22 +190,6 @@ func newKeyState() keyState {
 	}
 }
 
-func (ks keyState) marshalPackets() ([][]byte, error) {
-	var packets [][]byte
-	for _, ledID := range ks.ledIDs {
-		for i := 0; i < 3; i++ {
-			pkg, err := ks.marshalPacket(ledID, i)
-			if err != nil {
-				return nil, err
-			}
-
-			packets = append(packets, pkg)
-		}
-	}
-
-	return packets, nil
-}
-
 func (ks keyState) marshalPacket(ledID uint8, colorIndex int) ([]byte, error) {
 	const setKeyStateCommand = uint8(0x28)
 
@@ -223,14 +231,8 @@ const (
 	transitionFlag                = 4096  // == 1<<12
 )
 
-func (f *effectFlag) triggerOnApply() {
-	*f = *f | onApplyFlag
-}
-
 func (f *effectFlag) triggerNow() {
-	// clear onApplyFlag
-	nowFlag := effectFlag(1) ^ onApplyFlag
-	*f = *f & nowFlag
+	*f = *f &^ onApplyFlag
 }
 
 func (f *effectFlag) enableTransition() {
@@ -238,7 +240,5 @@ func (f *effectFlag) enableTransition() {
 }
 
 func (f *effectFlag) disableTransition() {
-	// clear transitionFlag
-	noTransitionFlag := effectFlag(1) ^ transitionFlag
-	*f = *f & noTransitionFlag
+	*f = *f &^ transitionFlag
 }
@@ -0,0 +1,186 @@
+package das
+
+import (
+	"context"
+	"errors"
+	"image/color"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/octo/das/dkb5q/fake"
+)
+
+// Only the initialization packet in this file is a golden value: it was
+// copied verbatim from diefarbe/node-lib, see initPacket. The expected effect
+// packets are written by hand from the field order in keyState.marshalPacket:
+// a header holding the command, channel and LED ID, followed by the little
+// endian "up" and "down" parameters, the start delay and the effect flags.
+// They catch unintended changes to the encoding, but have not been compared
+// with packets produced by node-lib.
+
+// staticPacket returns the packet setting a static color channel.
+func staticPacket(ledID, channel, value uint8) []byte {
+	return []byte{
+		0x00, 0x28, 0x00, channel, 0x01, ledID, 0x02,
+		value, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, // up
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, // down
+		0x00, 0x00, // start delay
+		0x00, 0x00,
+		0x01, 0x00, // incrementOnly
+	}
+}
+
+func TestKeyboard_initialize(t *testing.T) {
+	dev := &fake.HID{}
+	kb := New(dev)
+
+	if err := kb.initialize(context.Background()); err != nil {
+		t.Fatalf("initialize() = %v", err)
+	}
+
+	want := []fake.Report{
+		{ID: 0, Data: []byte{
+			0x00, 0x13, 0x00,
+			// "MCIQFIFEDLH9F4AECX916PBD5P3A3078"
+			0x4D, 0x43, 0x49, 0x51, 0x46, 0x49, 0x46, 0x45,
+			0x44, 0x4C, 0x48, 0x39, 0x46, 0x34, 0x41, 0x45,
+			0x43, 0x58, 0x39, 0x31, 0x36, 0x50, 0x42, 0x44,
+			0x35, 0x50, 0x33, 0x41, 0x33, 0x30, 0x37, 0x38,
+		}},
+	}
+	if diff := cmp.Diff(want, dev.Reports); diff != "" {
+		t.Errorf("reports differ (+got/-want):\n%s", diff)
+	}
+}
+
+func TestKeyState_marshalPacket(t *testing.T) {
+	cases := []struct {
+		name    string
+		effect  Effect
+		color   color.NRGBA
+		ledID   uint8
+		channel int
+		want    []byte
+	}{
+		{
+			name:    "static red, red channel",
+			effect:  Static(),
+			color:   color.NRGBA{R: 0xFF},
+			ledID:   0x2A,
+			channel: 0,
+			want:    staticPacket(0x2A, 0, 0xFF),
+		},
+		{
+			name:    "static red, green channel",
+			effect:  Static(),
+			color:   color.NRGBA{R: 0xFF},
+			ledID:   0x2A,
+			channel: 1,
+			want:    staticPacket(0x2A, 1, 0x00),
+		},
+		{
+			name:    "breathe",
+			effect:  Breathe(EffectDuration(2000)),
+			color:   color.NRGBA{R: 0x80},
+			ledID:   0x10,
+			channel: 0,
+			want: []byte{
+				0x00, 0x28, 0x00, 0x00, 0x01, 0x10, 0x02,
+				0x80, 0x01, 0x00, 0x07, 0x00, 0x80, 0x00, 0x00, 0x00, // up
+				0x00, 0x01, 0x00, 0x07, 0x00, 0x00, 0x00, 0x00, 0x00, // down
+				0x00, 0x00, // start delay
+				0x00, 0x00,
+				0x19, 0x00, // incrementDecrement
+			},
+		},
+		{
+			name:    "ramp with start delay",
+			effect:  Ramp(EffectDuration(510), StartDelay(300)),
+			color:   color.NRGBA{B: 0xFF},
+			ledID:   0x7F,
+			channel: 2,
+			want: []byte{
+				0x00, 0x28, 0x00, 0x02, 0x01, 0x7F, 0x02,
+				0xFF, 0x01, 0x00, 0x02, 0x00, 0xFF, 0x00, 0x00, 0x00, // up
+				0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, // down
+				0x2C, 0x01, // start delay
+				0x00, 0x00,
+				0x01, 0x00, // incrementOnly
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			ks := tc.effect.keyStates(tc.color)[tc.channel]
+			got, err := ks.marshalPacket(tc.ledID, tc.channel)
+			if err != nil {
+				t.Fatalf("marshalPacket() = %v", err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("packet differs (+got/-want):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestKeyboard_KeyColor(t *testing.T) {
+	dev := &fake.HID{}
+	kb := New(dev)
+
+	if err := kb.KeyColor(context.Background(), color.NRGBA{R: 0x01, G: 0x02, B: 0x03}, 0x05, 0x11); err != nil {
+		t.Fatalf("KeyColor() = %v", err)
+	}
+
+	want := []fake.Report{
+		{ID: 0, Data: staticPacket(0x05, 0, 0x01)},
+		{ID: 0, Data: staticPacket(0x05, 1, 0x02)},
+		{ID: 0, Data: staticPacket(0x05, 2, 0x03)},
+		{ID: 0, Data: staticPacket(0x11, 0, 0x01)},
+		{ID: 0, Data: staticPacket(0x11, 1, 0x02)},
+		{ID: 0, Data: staticPacket(0x11, 2, 0x03)},
+	}
+	if diff := cmp.Diff(want, dev.Reports); diff != "" {
+		t.Errorf("reports differ (+got/-want):\n%s", diff)
+	}
+}
+
+func TestKeyboard_Errors(t *testing.T) {
+	ctx := context.Background()
+	errTest := errors.New("test error")
+
+	t.Run("retries", func(t *testing.T) {
+		kb := New(&fake.HID{Err: errTest})
+		if err := kb.KeyColor(ctx, color.NRGBA{}, 0x11); !errors.Is(err, errTest) {
+			t.Errorf("KeyColor() = %v, want %v", err, errTest)
+		}
+	})
+
+	t.Run("cancelled", func(t *testing.T) {
+		dev := &fake.HID{}
+		kb := New(dev)
+
+		ctx, cancel := context.WithCancel(ctx)
+		cancel()
+		if err := kb.KeyColor(ctx, color.NRGBA{}, 0x11); !errors.Is(err, context.Canceled) {
+			t.Errorf("KeyColor() = %v, want %v", err, context.Canceled)
+		}
+		if len(dev.Reports) != 0 {
+			t.Errorf("got %d reports, want none", len(dev.Reports))
+		}
+	})
+
+	t.Run("closed", func(t *testing.T) {
+		dev := &fake.HID{}
+		kb := New(dev)
+		if err := kb.Close(); err != nil {
+			t.Fatalf("Close() = %v", err)
+		}
+		if !dev.Closed() {
+			t.Error("device has not been closed")
+		}
+		if err := kb.KeyColor(ctx, color.NRGBA{}, 0x11); !errors.Is(err, ErrNotOpen) {
+			t.Errorf("KeyColor() = %v, want %v", err, ErrNotOpen)
+		}
+	})
+}
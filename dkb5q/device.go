@@ -0,0 +1,43 @@
+package das
+
+import (
+	"errors"
+
+	"github.com/octo/das/internal/usb"
+)
+
+// DeviceInfo describes a "Das Keyboard" USB device.
+//
+// The hid package does not provide the bus path or the serial number of a
+// device, so devices are identified by their position in the enumeration
+// order (Index) instead.
+type DeviceInfo = usb.DeviceInfo
+
+// OpenError is returned by OpenWith if no device could be opened. It lists
+// the candidate devices and why they were skipped.
+type OpenError = usb.OpenError
+
+var errNotSelected = errors.New("not selected")
+
+// List returns all devices with the "Das Keyboard" vendor ID.
+func List() []DeviceInfo {
+	return usb.List()
+}
+
+// Selector selects the device opened by OpenWith. It returns true if the
+// device should be used.
+type Selector func(DeviceInfo) bool
+
+// ByProduct selects devices with the given USB product ID.
+func ByProduct(productID uint16) Selector {
+	return func(info DeviceInfo) bool {
+		return info.Product == productID
+	}
+}
+
+// ByIndex selects the device with the given index, as returned by List.
+func ByIndex(index int) Selector {
+	return func(info DeviceInfo) bool {
+		return info.Index == index
+	}
+}
@@ -0,0 +1,236 @@
+package das
+
+import (
+	"context"
+	"image/color"
+)
+
+// Effect describes how a key's light changes over time. Effects are created
+// with Static, Ramp, Fade, Breathe, Blink and ColorCycle, and applied with
+// Keyboard.KeyEffect.
+//
+// Durations and delays are given in Ticks, the keyboard's own delay unit.
+type Effect struct {
+	kind       effectKind
+	from       color.NRGBA
+	duration   Ticks
+	startDelay Ticks
+	transition bool
+}
+
+// Ticks is a delay in the keyboard's delay unit. The length of a tick is not
+// known; the defaults of the effects assume a tick is about a millisecond.
+type Ticks uint16
+
+type effectKind uint8
+
+const (
+	staticEffect effectKind = iota
+	rampEffect
+	breatheEffect
+	blinkEffect
+	colorCycleEffect
+)
+
+// EffectOption is an option to an effect. Not all effects support all
+// options – see the option's documentation for the effects they support.
+type EffectOption func(*Effect)
+
+// Static steadily lights the key in a single color.
+func Static(opts ...EffectOption) Effect {
+	return newEffect(staticEffect, 0, opts)
+}
+
+// Ramp fades the key in, from off to its color. The duration of the ramp
+// (default: 1000 ticks) can be controlled with EffectDuration.
+func Ramp(opts ...EffectOption) Effect {
+	return newEffect(rampEffect, 1000, opts)
+}
+
+// Fade fades the key from one color to another. The duration of the fade
+// (default: 1000 ticks) can be controlled with EffectDuration.
+func Fade(from color.NRGBA, opts ...EffectOption) Effect {
+	e := newEffect(rampEffect, 1000, opts)
+	e.from = from
+	return e
+}
+
+// Breathe cycles the key's light through continuous phases of high/low
+// intensity. The duration of one cycle (default: 2000 ticks) can be
+// controlled with EffectDuration.
+func Breathe(opts ...EffectOption) Effect {
+	return newEffect(breatheEffect, 2000, opts)
+}
+
+// Blink turns the key's light on/off at regular intervals. The duration of
+// one on/off cycle (default: 1050 ticks, meant to match the 4Q's 1.05 seconds)
+// can be controlled with EffectDuration.
+func Blink(opts ...EffectOption) Effect {
+	return newEffect(blinkEffect, 1050, opts)
+}
+
+// ColorCycle continuously cycles the key's light through the colors of the
+// rainbow. The duration of one cycle (default: 6000 ticks) can be controlled
+// with EffectDuration. The color passed to KeyEffect is ignored.
+func ColorCycle(opts ...EffectOption) Effect {
+	return newEffect(colorCycleEffect, 6000, opts)
+}
+
+func newEffect(kind effectKind, d Ticks, opts []EffectOption) Effect {
+	e := Effect{
+		kind:     kind,
+		duration: d,
+	}
+	for _, opt := range opts {
+		opt(&e)
+	}
+	return e
+}
+
+// EffectDuration sets the duration of the Ramp and Fade effects, and the
+// duration of one cycle of the Breathe, Blink and ColorCycle effects.
+func EffectDuration(d Ticks) EffectOption {
+	return func(e *Effect) {
+		if e.kind == staticEffect || d == 0 {
+			return
+		}
+		e.duration = d
+	}
+}
+
+// StartDelay delays the start of the effect. It is supported by all effects.
+func StartDelay(d Ticks) EffectOption {
+	return func(e *Effect) {
+		e.startDelay = d
+	}
+}
+
+// Transition sets the transition flag of the effect.
+//
+// node-lib sets this flag without documenting it. That it makes the effect
+// start from the key's current color is a guess which has not been checked
+// with a device.
+func Transition() EffectOption {
+	return func(e *Effect) {
+		e.transition = true
+	}
+}
+
+// addTicks returns a+b, saturating at the maximum value.
+func addTicks(a, b Ticks) uint16 {
+	if sum := uint32(a) + uint32(b); sum <= 0xFFFF {
+		return uint16(sum)
+	}
+	return 0xFFFF
+}
+
+// ramp returns the step size and the delay between steps to change a channel
+// by delta over d.
+func ramp(d Ticks, delta uint8) (step, delay uint16) {
+	if delta == 0 {
+		return 0, 0
+	}
+
+	units := uint16(d)
+	if units < 1 {
+		units = 1
+	}
+	if units >= uint16(delta) {
+		return 1, units / uint16(delta)
+	}
+	return (uint16(delta) + units - 1) / units, 1
+}
+
+// keyStates returns the state of each color channel, i.e. red, green and
+// blue, for showing c with effect e. Since the channels may change in
+// different directions, e.g. when fading from red to blue, each channel has
+// its own keyState. The channels use the default RGB channel order.
+func (e Effect) keyStates(c color.NRGBA) [3]keyState {
+	var (
+		from   = [3]uint8{e.from.R, e.from.G, e.from.B}
+		to     = [3]uint8{c.R, c.G, c.B}
+		states [3]keyState
+	)
+
+	for i := range states {
+		ks := newKeyState()
+		ks.startDelay = uint16(e.startDelay)
+
+		switch e.kind {
+		case staticEffect:
+			ks.colors[i].toValue = to[i]
+		case rampEffect:
+			if to[i] >= from[i] {
+				ks.colors[i].toValue = to[i]
+				ks.colors[i].fromValue = from[i]
+				ks.upIncrement, ks.upIncrementDelay = ramp(e.duration, to[i]-from[i])
+				ks.upHoldLevel = uint16(to[i])
+			} else {
+				// the channel is dimmed: start at the "up" value and
+				// decrement to the "down" value.
+				ks.effectFlag = decrementOnly
+				ks.colors[i].toValue = from[i]
+				ks.colors[i].fromValue = to[i]
+				ks.downDecrement, ks.downDecrementDelay = ramp(e.duration, from[i]-to[i])
+				ks.downHoldLevel = uint16(to[i])
+			}
+		case breatheEffect:
+			ks.effectFlag = incrementDecrement
+			ks.colors[i].toValue = to[i]
+			ks.upIncrement, ks.upIncrementDelay = ramp(e.duration/2, to[i])
+			ks.downDecrement, ks.downDecrementDelay = ramp(e.duration/2, to[i])
+			ks.upHoldLevel = uint16(to[i])
+		case blinkEffect:
+			// jump between on and off, and hold each for half a cycle.
+			ks.effectFlag = incrementDecrement
+			ks.colors[i].toValue = to[i]
+			ks.upIncrement = 0xFF
+			ks.downDecrement = 0xFF
+			ks.upHoldLevel = uint16(to[i])
+			ks.upHoldDelay = uint16(e.duration / 2)
+			ks.downHoldDelay = uint16(e.duration / 2)
+		case colorCycleEffect:
+			// each channel breathes, shifted by a third of a cycle.
+			ks.effectFlag = incrementDecrement
+			ks.colors[i].toValue = 0xFF
+			ks.upIncrement, ks.upIncrementDelay = ramp(e.duration/2, 0xFF)
+			ks.downDecrement, ks.downDecrementDelay = ramp(e.duration/2, 0xFF)
+			ks.upHoldLevel = 0xFF
+			ks.startDelay = addTicks(e.startDelay, Ticks(i)*(e.duration/3))
+		}
+
+		if e.transition {
+			ks.effectFlag.enableTransition()
+		}
+		states[i] = ks
+	}
+
+	return states
+}
+
+// KeyEffect shows c on one or more keys, identified by LED ID, using effect e.
+// c's alpha channel (c.A) is ignored. Each key's effect starts as soon as its
+// packets are received, i.e. keys are updated one after another. If ctx is
+// cancelled, KeyEffect returns without sending the remaining packets.
+func (kb Keyboard) KeyEffect(ctx context.Context, e Effect, c color.NRGBA, ledIDs ...uint8) error {
+	// TODO(octo): this mapping is not true for all keys.
+	states := e.keyStates(c)
+
+	for _, ledID := range ledIDs {
+		for i, ks := range states {
+			pkg, err := ks.marshalPacket(ledID, i)
+			if err != nil {
+				return err
+			}
+
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if err := kb.setReport(ctx, pkg); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
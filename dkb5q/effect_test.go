@@ -0,0 +1,98 @@
+package das
+
+import (
+	"image/color"
+	"testing"
+)
+
+func TestRamp(t *testing.T) {
+	cases := []struct {
+		d         Ticks
+		delta     uint8
+		wantStep  uint16
+		wantDelay uint16
+	}{
+		{1000, 0, 0, 0},
+		{1000, 100, 1, 10},
+		{1000, 255, 1, 3},
+		{100, 255, 3, 1},
+		{0, 255, 255, 1},
+	}
+
+	for _, tc := range cases {
+		step, delay := ramp(tc.d, tc.delta)
+		if step != tc.wantStep || delay != tc.wantDelay {
+			t.Errorf("ramp(%v, %d) = (%d, %d), want (%d, %d)", tc.d, tc.delta, step, delay, tc.wantStep, tc.wantDelay)
+		}
+	}
+}
+
+func TestEffect_keyStates(t *testing.T) {
+	var (
+		red  = color.NRGBA{R: 0xFF, A: 0xFF}
+		blue = color.NRGBA{B: 0xFF, A: 0xFF}
+	)
+
+	t.Run("static", func(t *testing.T) {
+		states := Static().keyStates(color.NRGBA{R: 1, G: 2, B: 3})
+		for i, want := range []uint8{1, 2, 3} {
+			ks := states[i]
+			if ks.colors[i].toValue != want || ks.effectFlag != incrementOnly || ks.upIncrement != 0 {
+				t.Errorf("channel %d = %+v, want static color %d", i, ks, want)
+			}
+		}
+	})
+
+	t.Run("fade", func(t *testing.T) {
+		states := Fade(red, EffectDuration(255)).keyStates(blue)
+
+		r := states[0]
+		if r.effectFlag != decrementOnly || r.colors[0].toValue != 0xFF || r.colors[0].fromValue != 0 || r.downDecrement != 1 || r.downDecrementDelay != 1 {
+			t.Errorf("red channel = %+v, want decrement from 0xFF to 0", r)
+		}
+		b := states[2]
+		if b.effectFlag != incrementOnly || b.colors[2].toValue != 0xFF || b.colors[2].fromValue != 0 || b.upIncrement != 1 || b.upIncrementDelay != 1 {
+			t.Errorf("blue channel = %+v, want increment from 0 to 0xFF", b)
+		}
+	})
+
+	t.Run("blink", func(t *testing.T) {
+		for i, ks := range Blink(StartDelay(1000), Transition()).keyStates(red) {
+			if want := effectFlag(incrementDecrement | transitionFlag); ks.effectFlag != want {
+				t.Errorf("channel %d: effectFlag = %d, want %d", i, ks.effectFlag, want)
+			}
+			if ks.upHoldDelay != 525 || ks.downHoldDelay != 525 {
+				t.Errorf("channel %d: hold delays = (%d, %d), want (525, 525)", i, ks.upHoldDelay, ks.downHoldDelay)
+			}
+			if ks.startDelay != 1000 {
+				t.Errorf("channel %d: startDelay = %d, want 1000", i, ks.startDelay)
+			}
+		}
+	})
+
+	t.Run("color cycle", func(t *testing.T) {
+		states := ColorCycle(EffectDuration(3000)).keyStates(color.NRGBA{})
+		for i, want := range []uint16{0, 1000, 2000} {
+			if got := states[i].startDelay; got != want {
+				t.Errorf("channel %d: startDelay = %d, want %d", i, got, want)
+			}
+		}
+	})
+}
+
+func TestEffectFlag(t *testing.T) {
+	f := effectFlag(incrementDecrement | onApplyFlag)
+
+	f.enableTransition()
+	if want := effectFlag(incrementDecrement | onApplyFlag | transitionFlag); f != want {
+		t.Errorf("effectFlag = %d, want %d", f, want)
+	}
+
+	// clearing a flag must not set it when it is unset.
+	f.triggerNow()
+	f.triggerNow()
+	f.disableTransition()
+	if want := effectFlag(incrementDecrement); f != want {
+		t.Errorf("effectFlag = %d, want %d", f, want)
+	}
+}
@@ -0,0 +1,54 @@
+// Package fake provides a fake HID device for testing code that uses the
+// dkb5q package without a keyboard attached.
+package fake
+
+import (
+	"errors"
+
+	"github.com/octo/retry"
+)
+
+// Report is a HID report with its report ID.
+type Report struct {
+	ID   int
+	Data []byte
+}
+
+// HID is a fake implementation of das.Device. Since the 5Q does not respond
+// to commands, HID records all reports sent to it, which can then be compared
+// to the expected packets. Use das.New to create a Keyboard using a HID.
+type HID struct {
+	// Reports holds all reports sent with SetReport, in order.
+	Reports []Report
+	// Err, if set, is returned by SetReport. The report is not recorded.
+	Err    error
+	closed bool
+}
+
+var errClosed = retry.Abort(errors.New("device is closed"))
+
+// Close implements das.Device.
+func (d *HID) Close() {
+	d.closed = true
+}
+
+// Closed returns true if Close has been called.
+func (d *HID) Closed() bool {
+	return d.closed
+}
+
+// SetReport implements das.Device.
+func (d *HID) SetReport(id int, data []byte) error {
+	if d.closed {
+		return errClosed
+	}
+	if d.Err != nil {
+		return d.Err
+	}
+
+	d.Reports = append(d.Reports, Report{
+		ID:   id,
+		Data: append([]byte{}, data...),
+	})
+	return nil
+}
@@ -0,0 +1,169 @@
+// Package driver provides a model-agnostic interface to "Das Keyboard"
+// devices. It is implemented by the driver in the dkb4q package.
+//
+// The 5Q is detected, but not supported: its key table, i.e. which LEDs light
+// which key, is not known, so keys cannot be addressed by name. Use package
+// dkb5q to address its LEDs directly.
+package driver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image/color"
+	"io"
+
+	"github.com/octo/das/dkb4q"
+	"github.com/octo/das/internal/usb"
+)
+
+// Keyboard is a keyboard of any supported model. Changes are staged with
+// SetColor and SetEffect, and sent to the keyboard with Commit. Whether all
+// staged keys change at once depends on the model: the 4Q applies them
+// atomically, other models may update one key after the other.
+//
+// Keys are identified by name, e.g. "F1". The keys of a model are returned by
+// Keys.
+type Keyboard interface {
+	// Keys returns the names of all keys of the keyboard.
+	Keys() []string
+	// SetColor stages a static color for keys.
+	SetColor(ctx context.Context, c color.NRGBA, keys ...string) error
+	// SetEffect stages an effect for keys. See the Effect constants for
+	// the names of effects. Not all models support all effects.
+	SetEffect(ctx context.Context, effect string, c color.NRGBA, keys ...string) error
+	// Commit sends all staged changes to the keyboard. Commit is not
+	// necessarily atomic, see above.
+	Commit(ctx context.Context) error
+	// Close closes the connection to the keyboard.
+	Close() error
+}
+
+var _ Keyboard = (*dkb4q.Keyboard)(nil)
+
+// Names of effects accepted by Keyboard.SetEffect.
+const (
+	EffectSetColor   = "set_color"
+	EffectBreathe    = "breathe"
+	EffectBlink      = "blink"
+	EffectColorCycle = "color_cycle"
+)
+
+// errUnsupported5Q is returned when opening a 5Q, see the package
+// documentation.
+var errUnsupported5Q = errors.New("the 5Q is not supported: its key table is not known")
+
+// Model is a keyboard model.
+type Model int
+
+const (
+	// UnknownModel is a "Das Keyboard" device not supported by this package.
+	UnknownModel Model = iota
+	// Model4Q is the "Das Keyboard 4Q", supported by package dkb4q.
+	Model4Q
+	// Model5Q is the "Das Keyboard 5Q". It is not supported by OpenAny,
+	// see the package documentation.
+	Model5Q
+)
+
+func (m Model) String() string {
+	switch m {
+	case Model4Q:
+		return "4Q"
+	case Model5Q:
+		return "5Q"
+	default:
+		return "unknown"
+	}
+}
+
+// USB product IDs of the supported models.
+//
+// The 5Q's product ID is believed to match diefarbe/node-lib, but has not
+// been checked against node-lib's source or a device.
+const (
+	Product4Q = 0x2037
+	Product5Q = 0x2020
+)
+
+// DeviceInfo describes a "Das Keyboard" USB device.
+type DeviceInfo = usb.DeviceInfo
+
+// List returns all "Das Keyboard" devices. Each USB interface is listed as a
+// separate device.
+func List() []DeviceInfo {
+	return usb.List()
+}
+
+// ModelOf returns the keyboard model of a USB device.
+func ModelOf(info DeviceInfo) Model {
+	switch info.Product {
+	case Product4Q:
+		return Model4Q
+	case Product5Q:
+		return Model5Q
+	default:
+		return UnknownModel
+	}
+}
+
+// Option is an option for OpenAny.
+type Option func(*options)
+
+type options struct {
+	trace io.Writer
+}
+
+// Tracing prints all communication with the keyboard to w. Not all models
+// support tracing; the option is ignored for those that don't.
+func Tracing(w io.Writer) Option {
+	return func(o *options) {
+		o.trace = w
+	}
+}
+
+// OpenAny opens the first supported keyboard and returns the driver for its
+// model, which is detected by the USB product ID. If no keyboard could be
+// opened, an error is returned for which errors.Is(err, dkb4q.ErrNotFound) is
+// true. ctx is used for the initialization some models require when opened.
+//
+// The connection to the keyboard should be closed with Close().
+func OpenAny(ctx context.Context, opts ...Option) (Keyboard, error) {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return openAny(ctx, usb.List(), o)
+}
+
+func openAny(ctx context.Context, devices []usb.DeviceInfo, o options) (Keyboard, error) {
+	openErr := &usb.OpenError{}
+	for _, info := range devices {
+		kb, err := openDevice(ctx, info, o)
+		if err != nil {
+			openErr.Skipped = append(openErr.Skipped, usb.SkippedDevice{Info: info, Reason: err})
+			continue
+		}
+		return kb, nil
+	}
+	return nil, openErr
+}
+
+func openDevice(ctx context.Context, info usb.DeviceInfo, o options) (Keyboard, error) {
+	switch ModelOf(info) {
+	case Model4Q:
+		var opts []dkb4q.Option
+		if o.trace != nil {
+			opts = append(opts, dkb4q.Tracing(dkb4q.WriterTracer(o.trace)))
+		}
+		kb, err := dkb4q.OpenWith(dkb4q.ByIndex(info.Index), opts...)
+		if err != nil {
+			return nil, err
+		}
+		return &kb, nil
+	case Model5Q:
+		return nil, errUnsupported5Q
+	default:
+		return nil, fmt.Errorf("unsupported product %#04x", info.Product)
+	}
+}
@@ -0,0 +1,47 @@
+package driver
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/octo/das/internal/usb"
+)
+
+func TestModelOf(t *testing.T) {
+	cases := []struct {
+		product uint16
+		want    Model
+	}{
+		{Product4Q, Model4Q},
+		{Product5Q, Model5Q},
+		{0x1234, UnknownModel},
+	}
+
+	for _, tc := range cases {
+		if got := ModelOf(usb.DeviceInfo{Product: tc.product}); got != tc.want {
+			t.Errorf("ModelOf(product %#04x) = %v, want %v", tc.product, got, tc.want)
+		}
+	}
+}
+
+func TestOpenAny_Unsupported(t *testing.T) {
+	_, err := openAny(context.Background(), []usb.DeviceInfo{
+		{Index: 0, Product: 0x1234},
+	}, options{})
+	if !errors.Is(err, usb.ErrNotFound) {
+		t.Errorf("openAny() = %v, want ErrNotFound", err)
+	}
+
+	var openErr *usb.OpenError
+	if !errors.As(err, &openErr) || len(openErr.Skipped) != 1 {
+		t.Errorf("openAny() = %#v, want *OpenError with one skipped device", err)
+	}
+}
+
+func TestOpenDevice_5Q(t *testing.T) {
+	_, err := openDevice(context.Background(), usb.DeviceInfo{Index: 0, Product: Product5Q}, options{})
+	if !errors.Is(err, errUnsupported5Q) {
+		t.Errorf("openDevice(5Q) = %v, want %v", err, errUnsupported5Q)
+	}
+}
@@ -0,0 +1,30 @@
+// Package cli contains code shared by the commands talking to a 4Q.
+package cli
+
+import (
+	"log"
+	"os"
+
+	"github.com/octo/das/dkb4q"
+)
+
+// NewConn returns a connection to the first 4Q found. The connection
+// reconnects when the keyboard is unplugged or the host is suspended, and
+// logs these events. If verbose is true, all communication with the keyboard
+// is printed to stdout.
+func NewConn(verbose bool) *dkb4q.Conn {
+	var opts []dkb4q.Option
+	if verbose {
+		opts = append(opts, dkb4q.Tracing(dkb4q.WriterTracer(os.Stdout)))
+	}
+
+	return dkb4q.NewConn(func() (dkb4q.Keyboard, error) {
+		return dkb4q.Open(opts...)
+	}, dkb4q.OnStateChange(func(s dkb4q.ConnState, err error) {
+		if err != nil {
+			log.Printf("keyboard %v: %v", s, err)
+			return
+		}
+		log.Printf("keyboard %v", s)
+	}))
+}
@@ -0,0 +1,134 @@
+// Package usb enumerates "Das Keyboard" USB devices. It is shared by the
+// drivers for the different keyboard models.
+package usb
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/zserge/hid"
+)
+
+// VendorID is the USB vendor ID of "Das Keyboard" devices.
+const VendorID = 0x24F0
+
+// ErrNotFound is returned by Open if no matching device is found.
+var ErrNotFound = errors.New("no Das Keyboard device found")
+
+// DeviceInfo describes a "Das Keyboard" USB device.
+//
+// The hid package does not provide the bus path or the serial number of a
+// device. Devices are therefore identified by their position in the
+// enumeration order instead.
+type DeviceInfo struct {
+	// Index is the position of the device among all devices with the
+	// "Das Keyboard" vendor ID. Each USB interface is a separate device.
+	Index     int
+	Product   uint16
+	Revision  uint16
+	Interface uint8
+}
+
+func (d DeviceInfo) String() string {
+	return fmt.Sprintf("#%d (product %#04x, revision %#04x, interface %d)", d.Index, d.Product, d.Revision, d.Interface)
+}
+
+func newDeviceInfo(index int, info hid.Info) DeviceInfo {
+	return DeviceInfo{
+		Index:     index,
+		Product:   info.Product,
+		Revision:  info.Revision,
+		Interface: info.Interface,
+	}
+}
+
+// List returns all devices with the "Das Keyboard" vendor ID.
+func List() []DeviceInfo {
+	var devices []DeviceInfo
+	hid.UsbWalk(func(dev hid.Device) {
+		if dev.Info().Vendor != VendorID {
+			return
+		}
+		devices = append(devices, newDeviceInfo(len(devices), dev.Info()))
+	})
+	return devices
+}
+
+// Open opens the first device for which match returns nil. The error returned
+// by match is recorded as the reason the device was skipped. If no device
+// could be opened, an *OpenError is returned.
+func Open(match func(DeviceInfo) error) (hid.Device, DeviceInfo, error) {
+	var (
+		device  hid.Device
+		info    DeviceInfo
+		skipped []SkippedDevice
+		lastErr error
+		index   int
+	)
+	hid.UsbWalk(func(dev hid.Device) {
+		if device != nil || dev.Info().Vendor != VendorID {
+			return
+		}
+
+		di := newDeviceInfo(index, dev.Info())
+		index++
+
+		if err := match(di); err != nil {
+			skipped = append(skipped, SkippedDevice{Info: di, Reason: err})
+			return
+		}
+
+		if err := dev.Open(); err != nil {
+			skipped = append(skipped, SkippedDevice{Info: di, Reason: fmt.Errorf("open: %w", err)})
+			lastErr = err
+			return
+		}
+
+		device = dev
+		info = di
+	})
+
+	if device == nil {
+		return nil, DeviceInfo{}, &OpenError{Skipped: skipped, Err: lastErr}
+	}
+
+	return device, info, nil
+}
+
+// SkippedDevice is a device that was not opened, and the reason why.
+type SkippedDevice struct {
+	Info   DeviceInfo
+	Reason error
+}
+
+// OpenError is returned by Open if no device could be opened. It lists all
+// candidate devices and the reasons they were skipped.
+type OpenError struct {
+	Skipped []SkippedDevice
+	// Err is the error of the last device that failed to open, if any.
+	Err error
+}
+
+func (e *OpenError) Error() string {
+	if len(e.Skipped) == 0 {
+		return ErrNotFound.Error()
+	}
+
+	var reasons []string
+	for _, s := range e.Skipped {
+		reasons = append(reasons, fmt.Sprintf("%v: %v", s.Info, s.Reason))
+	}
+	return fmt.Sprintf("%v; skipped %s", ErrNotFound, strings.Join(reasons, "; "))
+}
+
+// Is returns true for ErrNotFound, so that callers can use errors.Is to check
+// whether no device was opened.
+func (e *OpenError) Is(target error) bool {
+	return target == ErrNotFound
+}
+
+// Unwrap returns the error of the last device that failed to open, if any.
+func (e *OpenError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,59 @@
+package usb
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestOpenError(t *testing.T) {
+	errPermission := errors.New("permission denied")
+
+	cases := []struct {
+		title   string
+		err     *OpenError
+		want    string
+		wantErr error
+	}{
+		{
+			title: "no devices",
+			err:   &OpenError{},
+			want:  "no Das Keyboard device found",
+		},
+		{
+			title: "skipped devices",
+			err: &OpenError{
+				Skipped: []SkippedDevice{
+					{
+						Info:   DeviceInfo{Index: 0, Product: 0x2037, Revision: 0x0100, Interface: 0},
+						Reason: errors.New("interface 0, want 1"),
+					},
+					{
+						Info:   DeviceInfo{Index: 1, Product: 0x2037, Revision: 0x0100, Interface: 1},
+						Reason: errPermission,
+					},
+				},
+				Err: errPermission,
+			},
+			want: "no Das Keyboard device found; " +
+				"skipped #0 (product 0x2037, revision 0x0100, interface 0): interface 0, want 1; " +
+				"#1 (product 0x2037, revision 0x0100, interface 1): permission denied",
+			wantErr: errPermission,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.title, func(t *testing.T) {
+			var err error = tc.err
+
+			if got := err.Error(); got != tc.want {
+				t.Errorf("Error() = %q, want %q", got, tc.want)
+			}
+			if !errors.Is(err, ErrNotFound) {
+				t.Errorf("errors.Is(%v, ErrNotFound) = false, want true", err)
+			}
+			if tc.wantErr != nil && !errors.Is(err, tc.wantErr) {
+				t.Errorf("errors.Is(%v, %v) = false, want true", err, tc.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,183 @@
+// Package profile reads and writes keyboard profiles: the idle and active
+// effects and colors of keys, stored as JSON. For example:
+//
+//	{
+//	  "groups": {
+//	    "fkeys": ["F1", "F2", "F3", "F4"]
+//	  },
+//	  "keys": [
+//	    {
+//	      "keys": ["fkeys", "Esc"],
+//	      "idleEffect": "breathe",
+//	      "idleColor": "#0000FF",
+//	      "activeEffect": {"effect": "blink", "cycleDuration": "1.05s", "cycleCount": 3},
+//	      "activeColor": "#FF0000"
+//	    }
+//	  ]
+//	}
+//
+// Entries are applied in order, i.e. if a key is listed in multiple entries,
+// the last entry wins. The encoding of effects is described by the
+// MarshalJSON methods of dkb4q.IdleEffect and dkb4q.ActiveEffect.
+package profile
+
+import (
+	"encoding/json"
+	"fmt"
+	"image/color"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/octo/das/dkb4q"
+)
+
+// Profile describes the state of keys.
+type Profile struct {
+	// Groups are named sets of keys, which can be used instead of key
+	// names in entries.
+	Groups map[string][]string `json:"groups,omitempty"`
+	Keys   []Entry             `json:"keys"`
+}
+
+// Entry is the state of one or more keys.
+type Entry struct {
+	// Keys are key names, see dkb4q.KeyByName, or group names.
+	Keys         []string           `json:"keys"`
+	IdleEffect   dkb4q.IdleEffect   `json:"idleEffect"`
+	IdleColor    Color              `json:"idleColor"`
+	ActiveEffect dkb4q.ActiveEffect `json:"activeEffect"`
+	ActiveColor  Color              `json:"activeColor"`
+}
+
+// Color is a color encoded as "#RRGGBB", or "#RRGGBBAA" if the color is not
+// opaque.
+type Color color.NRGBA
+
+// MarshalText implements encoding.TextMarshaler.
+func (c Color) MarshalText() ([]byte, error) {
+	if c.A == 0xFF {
+		return []byte(fmt.Sprintf("#%02X%02X%02X", c.R, c.G, c.B)), nil
+	}
+	return []byte(fmt.Sprintf("#%02X%02X%02X%02X", c.R, c.G, c.B, c.A)), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler. See ParseColor for the
+// accepted formats.
+func (c *Color) UnmarshalText(text []byte) error {
+	v, err := ParseColor(string(text))
+	if err != nil {
+		return err
+	}
+	*c = Color(v)
+	return nil
+}
+
+// ParseColor parses colors in the "#RRGGBB" and "#RRGGBBAA" formats. The "#"
+// is optional. Colors without alpha channel are opaque.
+func ParseColor(s string) (color.NRGBA, error) {
+	hex := strings.TrimPrefix(s, "#")
+	if len(hex) == 6 {
+		hex += "FF"
+	}
+	if len(hex) != 8 {
+		return color.NRGBA{}, fmt.Errorf("invalid color %q, want #RRGGBB", s)
+	}
+
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return color.NRGBA{}, fmt.Errorf("invalid color %q, want #RRGGBB", s)
+	}
+	return color.NRGBA{R: uint8(v >> 24), G: uint8(v >> 16), B: uint8(v >> 8), A: uint8(v)}, nil
+}
+
+// Read reads a profile in JSON format.
+func Read(r io.Reader) (Profile, error) {
+	var p Profile
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&p); err != nil {
+		return Profile{}, err
+	}
+	return p, nil
+}
+
+// Write writes p in JSON format.
+func Write(w io.Writer, p Profile) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(p)
+}
+
+// FromStates returns a profile with one entry per key.
+func FromStates(states ...dkb4q.State) Profile {
+	var p Profile
+	for _, s := range states {
+		p.Keys = append(p.Keys, Entry{
+			Keys:         []string{s.ID.String()},
+			IdleEffect:   s.IdleEffect,
+			IdleColor:    Color(s.IdleColor),
+			ActiveEffect: s.ActiveEffect,
+			ActiveColor:  Color(s.ActiveColor),
+		})
+	}
+	return p
+}
+
+// States returns the state of all keys in the profile, ordered by key ID. The
+// keys must be names of 4Q keys, see dkb4q.KeyByName.
+func (p Profile) States() ([]dkb4q.State, error) {
+	byKey := make(map[dkb4q.Key]dkb4q.State)
+	for i, e := range p.Keys {
+		keys, err := p.resolve(e.Keys)
+		if err != nil {
+			return nil, fmt.Errorf("entry %d: %w", i, err)
+		}
+
+		for _, k := range keys {
+			byKey[k] = dkb4q.State{
+				ID:           k,
+				IdleEffect:   e.IdleEffect,
+				IdleColor:    color.NRGBA(e.IdleColor),
+				ActiveEffect: e.ActiveEffect,
+				ActiveColor:  color.NRGBA(e.ActiveColor),
+			}
+		}
+	}
+
+	states := make([]dkb4q.State, 0, len(byKey))
+	for _, s := range byKey {
+		states = append(states, s)
+	}
+	sort.Slice(states, func(i, j int) bool { return states[i].ID < states[j].ID })
+	return states, nil
+}
+
+// KeyNames replaces the group names in names with the group members. Group
+// names take precedence over key names. The key names are not checked, which
+// allows using the profile with models other than the 4Q.
+func (p Profile) KeyNames(names []string) []string {
+	var ret []string
+	for _, name := range names {
+		if group, ok := p.Groups[name]; ok {
+			ret = append(ret, group...)
+			continue
+		}
+		ret = append(ret, name)
+	}
+	return ret
+}
+
+// resolve returns the 4Q keys of key and group names.
+func (p Profile) resolve(names []string) ([]dkb4q.Key, error) {
+	var keys []dkb4q.Key
+	for _, name := range p.KeyNames(names) {
+		k, err := dkb4q.KeyByName(name)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, k)
+	}
+	return keys, nil
+}
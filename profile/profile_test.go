@@ -0,0 +1,148 @@
+package profile
+
+import (
+	"bytes"
+	"image/color"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/octo/das/dkb4q"
+)
+
+var (
+	red  = color.NRGBA{R: 0xFF, A: 0xFF}
+	blue = color.NRGBA{B: 0xFF, A: 0xFF}
+)
+
+func TestRoundTrip(t *testing.T) {
+	states := []dkb4q.State{
+		{ID: dkb4q.KeyEsc, IdleEffect: dkb4q.SetColor, IdleColor: red, ActiveEffect: dkb4q.None},
+		{ID: dkb4q.KeyF1, IdleEffect: dkb4q.Breathe, IdleColor: blue, ActiveEffect: dkb4q.SetColorActive(), ActiveColor: red},
+		{ID: dkb4q.KeyF2, IdleEffect: dkb4q.Blink, IdleColor: blue, ActiveEffect: dkb4q.SetColorActive(dkb4q.EffectDuration(5 * time.Second)), ActiveColor: red},
+		{ID: dkb4q.KeyF3, IdleEffect: dkb4q.ColorCycle, ActiveEffect: dkb4q.BlinkActive(), ActiveColor: red},
+		{ID: dkb4q.KeyF4, IdleEffect: dkb4q.SetColor, ActiveEffect: dkb4q.BlinkActive(dkb4q.CycleCount(10), dkb4q.CycleDuration(300*time.Millisecond)), ActiveColor: blue},
+		{ID: dkb4q.KeyF5, IdleEffect: dkb4q.SetColor, ActiveEffect: dkb4q.BreatheActive(), ActiveColor: color.NRGBA{R: 0x12, G: 0x34, B: 0x56, A: 0x78}},
+		{ID: dkb4q.KeyF6, IdleEffect: dkb4q.IdleEffect(0x07), ActiveEffect: dkb4q.BreatheActive(dkb4q.CycleCount(1)), ActiveColor: blue},
+	}
+
+	var buf bytes.Buffer
+	if err := Write(&buf, FromStates(states...)); err != nil {
+		t.Fatalf("Write() = %v", err)
+	}
+
+	p, err := Read(&buf)
+	if err != nil {
+		t.Fatalf("Read() = %v", err)
+	}
+
+	got, err := p.States()
+	if err != nil {
+		t.Fatalf("Profile.States() = %v", err)
+	}
+
+	if diff := cmp.Diff(states, got, cmp.AllowUnexported(dkb4q.ActiveEffect{})); diff != "" {
+		t.Errorf("Profile.States() differs (+got/-want):\n%s", diff)
+	}
+}
+
+func TestProfile_States(t *testing.T) {
+	const input = `{
+  "groups": {
+    "fkeys": ["F1", "F2"]
+  },
+  "keys": [
+    {
+      "keys": ["fkeys", "Esc"],
+      "idleEffect": "breathe",
+      "idleColor": "#0000FF",
+      "activeEffect": {"effect": "blink", "cycleCount": 5},
+      "activeColor": "#FF0000"
+    },
+    {
+      "keys": ["F2"],
+      "idleEffect": "set_color",
+      "idleColor": "#FF0000"
+    }
+  ]
+}`
+
+	p, err := Read(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Read() = %v", err)
+	}
+
+	got, err := p.States()
+	if err != nil {
+		t.Fatalf("Profile.States() = %v", err)
+	}
+
+	want := []dkb4q.State{
+		{ID: dkb4q.KeyEsc, IdleEffect: dkb4q.Breathe, IdleColor: blue, ActiveEffect: dkb4q.BlinkActive(dkb4q.CycleCount(5)), ActiveColor: red},
+		{ID: dkb4q.KeyF1, IdleEffect: dkb4q.Breathe, IdleColor: blue, ActiveEffect: dkb4q.BlinkActive(dkb4q.CycleCount(5)), ActiveColor: red},
+		// the later entry overrides the group.
+		{ID: dkb4q.KeyF2, IdleEffect: dkb4q.SetColor, IdleColor: red, ActiveEffect: dkb4q.None},
+	}
+	if diff := cmp.Diff(want, got, cmp.AllowUnexported(dkb4q.ActiveEffect{})); diff != "" {
+		t.Errorf("Profile.States() differs (+got/-want):\n%s", diff)
+	}
+}
+
+func TestRead_Errors(t *testing.T) {
+	cases := []string{
+		`{"keys": [{"keys": ["F1"], "idleEffect": "sparkle"}]}`,
+		`{"keys": [{"keys": ["F1"], "idleColor": "red"}]}`,
+		`{"keys": [{"keys": ["F1"], "activeEffect": {"effect": "blink", "cycleCount": 300}}]}`,
+		`{"keys": [{"keys": ["F1"], "idleColour": "#FF0000"}]}`,
+	}
+
+	for _, input := range cases {
+		if _, err := Read(strings.NewReader(input)); err == nil {
+			t.Errorf("Read(%s) succeeded, want error", input)
+		}
+	}
+}
+
+func TestProfile_StatesErrors(t *testing.T) {
+	cases := []Profile{
+		{Keys: []Entry{{Keys: []string{"NoSuchKey"}}}},
+		{
+			Groups: map[string][]string{"broken": {"F1", "NoSuchKey"}},
+			Keys:   []Entry{{Keys: []string{"broken"}}},
+		},
+	}
+
+	for _, p := range cases {
+		if _, err := p.States(); err == nil {
+			t.Errorf("Profile%+v.States() succeeded, want error", p)
+		}
+	}
+}
+
+func TestColor(t *testing.T) {
+	cases := []struct {
+		text    string
+		want    Color
+		wantErr bool
+	}{
+		{"#FF0000", Color(red), false},
+		{"#0000ff", Color(blue), false},
+		{"#12345678", Color{R: 0x12, G: 0x34, B: 0x56, A: 0x78}, false},
+		{"FF0000", Color(red), false},
+		{"#F00", Color{}, true},
+		{"#GG0000", Color{}, true},
+	}
+
+	for _, tc := range cases {
+		var got Color
+		err := got.UnmarshalText([]byte(tc.text))
+		if gotErr := err != nil; gotErr != tc.wantErr {
+			t.Errorf("UnmarshalText(%q) = %v, want error %v", tc.text, err, tc.wantErr)
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("UnmarshalText(%q) = %v, want %v", tc.text, got, tc.want)
+		}
+	}
+}
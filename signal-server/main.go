@@ -0,0 +1,35 @@
+// signal-server serves a subset of the Das Keyboard Q REST signal API and
+// talks to the keyboard directly. Integrations using the signal API, e.g.
+//
+//	curl -X POST -H "Content-Type: application/json" \
+//		-d '{"zoneId": "KEY_A", "color": "#FF0000", "effect": "BLINK"}' \
+//		http://localhost:27301/api/1.0/signals
+//
+// work unchanged, with the latency of talking to the keyboard directly.
+//
+// The vendor's software must not be running at the same time, since it uses
+// the same port and USB device.
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+
+	"github.com/octo/das/internal/cli"
+)
+
+var (
+	listen  = flag.String("listen", "localhost:27301", "address to listen on")
+	verbose = flag.Bool("verbose", false, "print all communication with the keyboard")
+)
+
+func main() {
+	flag.Parse()
+
+	conn := cli.NewConn(*verbose)
+	defer conn.Close()
+
+	log.Printf("listening on %s", *listen)
+	log.Fatal(http.ListenAndServe(*listen, newServer(conn)))
+}
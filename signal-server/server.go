@@ -0,0 +1,253 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/octo/das/dkb4q"
+	"github.com/octo/das/profile"
+)
+
+// signal is a signal as sent and returned by the Das Keyboard Q REST API.
+// Fields not used by this server, e.g. "message", are accepted and returned,
+// but otherwise ignored.
+type signal struct {
+	ID         int64  `json:"id,omitempty"`
+	PID        string `json:"pid,omitempty"`
+	ZoneID     string `json:"zoneId"`
+	Color      string `json:"color"`
+	Effect     string `json:"effect,omitempty"`
+	Name       string `json:"name,omitempty"`
+	Message    string `json:"message,omitempty"`
+	ClientName string `json:"clientName,omitempty"`
+	CreatedAt  int64  `json:"createdAt,omitempty"`
+}
+
+// server implements the subset of the signal API supported by the 4Q:
+//
+//	POST   /api/1.0/signals
+//	DELETE /api/1.0/signals/pid/{pid}/zoneId/{zoneId}
+//
+// Signals are shown as notifications on top of the keyboard's state. Deleting
+// a signal dismisses it, revealing what the key showed before.
+type server struct {
+	n   *dkb4q.Notifier
+	now func() time.Time
+
+	mu      sync.Mutex
+	lastID  int64
+	signals map[signalKey]dkb4q.NotificationID
+}
+
+// signalKey identifies a signal for the DELETE endpoint. Posting a signal for
+// the same pid and zone replaces the previous one.
+type signalKey struct {
+	pid string
+	key dkb4q.Key
+}
+
+func newServer(kb dkb4q.StateSetter) *server {
+	return &server{
+		n:       dkb4q.NewNotifier(kb),
+		now:     time.Now,
+		signals: make(map[signalKey]dkb4q.NotificationID),
+	}
+}
+
+const signalsPath = "/api/1.0/signals"
+
+func (s *server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.Method == http.MethodPost && r.URL.Path == signalsPath:
+		s.createSignal(w, r)
+	case r.Method == http.MethodDelete && strings.HasPrefix(r.URL.Path, signalsPath+"/pid/"):
+		s.deleteSignal(w, r)
+	default:
+		httpError(w, http.StatusNotFound, fmt.Errorf("%s %s is not supported", r.Method, r.URL.Path))
+	}
+}
+
+func (s *server) createSignal(w http.ResponseWriter, r *http.Request) {
+	var sig signal
+	if err := json.NewDecoder(r.Body).Decode(&sig); err != nil {
+		httpError(w, http.StatusBadRequest, fmt.Errorf("decoding signal: %w", err))
+		return
+	}
+
+	state, err := sig.state()
+	if err != nil {
+		httpError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id, err := s.n.Notify(r.Context(), state, 0)
+	if err != nil {
+		httpError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	k := signalKey{pid: sig.PID, key: state.ID}
+	if old, ok := s.signals[k]; ok {
+		if err := s.n.Dismiss(r.Context(), old); err != nil {
+			httpError(w, http.StatusInternalServerError, err)
+			return
+		}
+	}
+	s.signals[k] = id
+
+	s.lastID++
+	sig.ID = s.lastID
+	sig.CreatedAt = s.now().UnixNano() / int64(time.Millisecond)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(sig)
+}
+
+// deleteSignal dismisses the signal of a pid and zone. Deleting a signal that
+// does not exist is not an error.
+func (s *server) deleteSignal(w http.ResponseWriter, r *http.Request) {
+	// pid/{pid}/zoneId/{zoneId}
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, signalsPath+"/"), "/")
+	if len(parts) != 4 || parts[2] != "zoneId" {
+		httpError(w, http.StatusNotFound, fmt.Errorf("invalid path %q", r.URL.Path))
+		return
+	}
+
+	key, err := parseZoneID(parts[3])
+	if err != nil {
+		httpError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	k := signalKey{pid: parts[1], key: key}
+	if id, ok := s.signals[k]; ok {
+		if err := s.n.Dismiss(r.Context(), id); err != nil {
+			httpError(w, http.StatusInternalServerError, err)
+			return
+		}
+		delete(s.signals, k)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func httpError(w http.ResponseWriter, code int, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(struct {
+		Message string `json:"message"`
+	}{
+		Message: err.Error(),
+	})
+}
+
+// state returns the key state requested by the signal.
+func (sig signal) state() (dkb4q.State, error) {
+	id, err := parseZoneID(sig.ZoneID)
+	if err != nil {
+		return dkb4q.State{}, err
+	}
+
+	c, err := profile.ParseColor(sig.Color)
+	if err != nil {
+		return dkb4q.State{}, err
+	}
+
+	effect, ok := effects[strings.ToUpper(sig.Effect)]
+	if !ok {
+		return dkb4q.State{}, fmt.Errorf("unsupported effect %q", sig.Effect)
+	}
+
+	return dkb4q.State{
+		ID:           id,
+		IdleEffect:   effect,
+		IdleColor:    c,
+		ActiveEffect: dkb4q.None,
+	}, nil
+}
+
+// effects maps the effects of the signal API to idle effects. An empty effect
+// defaults to SET_COLOR, like in the signal API.
+var effects = map[string]dkb4q.IdleEffect{
+	"":            dkb4q.SetColor,
+	"SET_COLOR":   dkb4q.SetColor,
+	"BREATHE":     dkb4q.Breathe,
+	"BLINK":       dkb4q.Blink,
+	"COLOR_CYCLE": dkb4q.ColorCycle,
+}
+
+// zoneAliases maps key names of the signal API, without the "KEY_" prefix and
+// underscores, to the names used by dkb4q. Names that only differ in case and
+// underscores, e.g. "KEY_PAGE_UP", are matched without an alias.
+var zoneAliases = map[string]string{
+	"ESCAPE":       "Esc",
+	"SPACEBAR":     "Space",
+	"SHIFTLEFT":    "LeftShift",
+	"SHIFTRIGHT":   "RightShift",
+	"CONTROLLEFT":  "LeftCtrl",
+	"CONTROLRIGHT": "RightCtrl",
+	"ALTLEFT":      "LeftAlt",
+	"ALTRIGHT":     "RightAlt",
+	"METALEFT":     "LeftMeta",
+	"METARIGHT":    "RightMeta",
+	"CONTEXTMENU":  "Menu",
+	"ARROWLEFT":    "Left",
+	"ARROWRIGHT":   "Right",
+	"ARROWUP":      "Up",
+	"ARROWDOWN":    "Down",
+	"BACKTICK":     "Backquote",
+	"DEL":          "Delete",
+}
+
+// parseZoneID parses a zone ID of the signal API into a key. Zones are either
+// key names, e.g. "KEY_A", or coordinates "x,y", where x is the column and y
+// the row, counted from the function key row.
+func parseZoneID(zone string) (dkb4q.Key, error) {
+	if x, y, ok := parseCoordinates(zone); ok {
+		const rows = 6
+		id := rows*x + (rows - 1 - y)
+		if y >= rows || id > dkb4q.MaxID {
+			return 0, fmt.Errorf("invalid zone %q", zone)
+		}
+		return dkb4q.Key(id), nil
+	}
+
+	name := strings.ToUpper(zone)
+	if !strings.HasPrefix(name, "KEY_") {
+		return 0, fmt.Errorf("invalid zone %q", zone)
+	}
+	name = strings.ReplaceAll(strings.TrimPrefix(name, "KEY_"), "_", "")
+	if alias, ok := zoneAliases[name]; ok {
+		name = alias
+	}
+
+	id, err := dkb4q.KeyByName(name)
+	if err != nil {
+		return 0, fmt.Errorf("invalid zone %q: %w", zone, err)
+	}
+	return id, nil
+}
+
+func parseCoordinates(zone string) (x, y int, ok bool) {
+	fields := strings.Split(zone, ",")
+	if len(fields) != 2 {
+		return 0, 0, false
+	}
+	x, errX := strconv.Atoi(strings.TrimSpace(fields[0]))
+	y, errY := strconv.Atoi(strings.TrimSpace(fields[1]))
+	if errX != nil || errY != nil || x < 0 || y < 0 {
+		return 0, 0, false
+	}
+	return x, y, true
+}
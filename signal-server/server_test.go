@@ -0,0 +1,203 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"image/color"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/octo/das/dkb4q"
+	"github.com/octo/das/dkb4q/fake"
+)
+
+func TestServer(t *testing.T) {
+	red := color.NRGBA{R: 0xFF, A: 0xFF}
+
+	cases := []struct {
+		name     string
+		method   string
+		path     string
+		body     string
+		wantCode int
+		wantKey  dkb4q.Key
+		want     fake.KeyState
+	}{
+		{
+			name:     "set color",
+			method:   http.MethodPost,
+			path:     "/api/1.0/signals",
+			body:     `{"pid": "DK4QPID", "zoneId": "KEY_A", "color": "#FF0000", "effect": "SET_COLOR", "name": "test"}`,
+			wantCode: http.StatusOK,
+			wantKey:  dkb4q.KeyA,
+			want:     fake.KeyState{IdleEffect: uint8(dkb4q.SetColor), IdleColor: red, ActiveColor: color.NRGBA{A: 0xFF}},
+		},
+		{
+			name:     "default effect",
+			method:   http.MethodPost,
+			path:     "/api/1.0/signals",
+			body:     `{"zoneId": "KEY_ESCAPE", "color": "#ff0000"}`,
+			wantCode: http.StatusOK,
+			wantKey:  dkb4q.KeyEsc,
+			want:     fake.KeyState{IdleEffect: uint8(dkb4q.SetColor), IdleColor: red, ActiveColor: color.NRGBA{A: 0xFF}},
+		},
+		{
+			name:     "blink",
+			method:   http.MethodPost,
+			path:     "/api/1.0/signals",
+			body:     `{"zoneId": "KEY_PAGE_UP", "color": "#FF0000", "effect": "BLINK"}`,
+			wantCode: http.StatusOK,
+			wantKey:  dkb4q.KeyPageUp,
+			want:     fake.KeyState{IdleEffect: uint8(dkb4q.Blink), IdleColor: red, ActiveColor: color.NRGBA{A: 0xFF}},
+		},
+		{
+			name:     "coordinates",
+			method:   http.MethodPost,
+			path:     "/api/1.0/signals",
+			body:     `{"zoneId": "2,0", "color": "#FF0000", "effect": "BREATHE"}`,
+			wantCode: http.StatusOK,
+			wantKey:  dkb4q.KeyF1,
+			want:     fake.KeyState{IdleEffect: uint8(dkb4q.Breathe), IdleColor: red, ActiveColor: color.NRGBA{A: 0xFF}},
+		},
+		{
+			name:     "invalid zone",
+			method:   http.MethodPost,
+			path:     "/api/1.0/signals",
+			body:     `{"zoneId": "KEY_DOES_NOT_EXIST", "color": "#FF0000"}`,
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "invalid coordinates",
+			method:   http.MethodPost,
+			path:     "/api/1.0/signals",
+			body:     `{"zoneId": "100,0", "color": "#FF0000"}`,
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "invalid color",
+			method:   http.MethodPost,
+			path:     "/api/1.0/signals",
+			body:     `{"zoneId": "KEY_A", "color": "red"}`,
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "unsupported effect",
+			method:   http.MethodPost,
+			path:     "/api/1.0/signals",
+			body:     `{"zoneId": "KEY_A", "color": "#FF0000", "effect": "RIPPLE"}`,
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "unsupported method",
+			method:   http.MethodGet,
+			path:     "/api/1.0/signals",
+			wantCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			sim := fake.NewSimulator()
+			kb := dkb4q.New(sim)
+			defer kb.Close()
+
+			srv := newServer(&kb)
+			srv.now = func() time.Time { return time.Unix(1600000000, 0) }
+
+			req := httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body))
+			rec := httptest.NewRecorder()
+			srv.ServeHTTP(rec, req)
+
+			if rec.Code != tc.wantCode {
+				t.Fatalf("%s %s: status %d, want %d; body: %s", tc.method, tc.path, rec.Code, tc.wantCode, rec.Body)
+			}
+			if tc.wantCode >= 300 {
+				return
+			}
+
+			got, ok := sim.Committed(uint8(tc.wantKey))
+			if !ok {
+				t.Fatalf("key %v has not been committed", tc.wantKey)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("key %v differs (+got/-want):\n%s", tc.wantKey, diff)
+			}
+		})
+	}
+}
+
+func TestServer_Response(t *testing.T) {
+	kb := dkb4q.New(fake.NewSimulator())
+	defer kb.Close()
+
+	srv := newServer(&kb)
+	srv.now = func() time.Time { return time.Unix(1600000000, 0) }
+
+	for _, wantID := range []int64{1, 2} {
+		req := httptest.NewRequest(http.MethodPost, "/api/1.0/signals",
+			strings.NewReader(`{"zoneId": "KEY_A", "color": "#FF0000", "message": "hello"}`))
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, req)
+
+		var got signal
+		if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+			t.Fatal(err)
+		}
+		want := signal{
+			ID:        wantID,
+			ZoneID:    "KEY_A",
+			Color:     "#FF0000",
+			Message:   "hello",
+			CreatedAt: 1600000000000,
+		}
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Errorf("response differs (+got/-want):\n%s", diff)
+		}
+	}
+}
+
+func TestServer_Delete(t *testing.T) {
+	var (
+		ctx   = context.Background()
+		green = color.NRGBA{G: 0xFF, A: 0xFF}
+	)
+
+	sim := fake.NewSimulator()
+	kb := dkb4q.New(sim)
+	defer kb.Close()
+
+	// F12 is set by something other than the server.
+	if err := kb.SetState(ctx, dkb4q.State{ID: dkb4q.KeyF12, IdleEffect: dkb4q.Breathe, IdleColor: green}); err != nil {
+		t.Fatal(err)
+	}
+
+	srv := newServer(&kb)
+
+	do := func(method, path, body string, wantCode int) {
+		t.Helper()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, req)
+		if rec.Code != wantCode {
+			t.Fatalf("%s %s: status %d, want %d; body: %s", method, path, rec.Code, wantCode, rec.Body)
+		}
+	}
+
+	// The second signal replaces the first one.
+	do(http.MethodPost, "/api/1.0/signals", `{"pid": "DK4QPID", "zoneId": "KEY_F12", "color": "#FF0000", "effect": "BLINK"}`, http.StatusOK)
+	do(http.MethodPost, "/api/1.0/signals", `{"pid": "DK4QPID", "zoneId": "KEY_F12", "color": "#0000FF"}`, http.StatusOK)
+	do(http.MethodDelete, "/api/1.0/signals/pid/DK4QPID/zoneId/KEY_F12", "", http.StatusNoContent)
+
+	want := fake.KeyState{IdleEffect: uint8(dkb4q.Breathe), IdleColor: green, ActiveColor: color.NRGBA{A: 0xFF}}
+	got, _ := sim.Committed(uint8(dkb4q.KeyF12))
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("key %v differs (+got/-want):\n%s", dkb4q.KeyF12, diff)
+	}
+
+	// Deleting a signal that does not exist is not an error.
+	do(http.MethodDelete, "/api/1.0/signals/pid/DK4QPID/zoneId/KEY_F12", "", http.StatusNoContent)
+}